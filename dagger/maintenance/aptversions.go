@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"dagger/maintenance/internal/dagger"
+)
+
+// madisonEntry is a version of a package listed by apt-cache madison.
+type madisonEntry struct {
+	Package string
+	Version string
+	// Source is the repository the version comes from (e.g. "http://apt.postgresql.org/pub/repos/apt trixie-pgdg/main amd64 Packages")
+	Source string
+}
+
+// pgdgRepositoryRegex matches the URL of the pgdg repository in the apt sources
+const pgdgRepositoryRegex = `https\?://apt\.postgresql\.org/pub/repos/apt`
+
+// pgdgContainer returns the PostgreSQL base image of a distribution and PG
+// major with up-to-date apt lists, where the pgdg repository is configured.
+// When a mirror is given, it replaces the pgdg repository.
+func pgdgContainer(distribution string, majorVersion string, mirror string) *dagger.Container {
+	postgresBaseImage := fmt.Sprintf("ghcr.io/cloudnative-pg/postgresql:%s-minimal-%s", majorVersion, distribution)
+
+	container := dag.Container().
+		From(postgresBaseImage).
+		WithUser("root")
+	if mirror != "" {
+		container = container.
+			WithEnvVariable("PGDG_MIRROR", mirror).
+			WithExec([]string{"sh", "-c", fmt.Sprintf(
+				`for f in /etc/apt/sources.list /etc/apt/sources.list.d/*; do `+
+					`[ -f "$f" ] && sed -i "s#%s#${PGDG_MIRROR}#g" "$f"; done; true`, pgdgRepositoryRegex)})
+	}
+
+	return container.WithExec([]string{"apt-get", "update"})
+}
+
+// getPgdgVersions returns the newest pgdg version of each package available
+// in the container. Packages unknown to apt are omitted.
+func getPgdgVersions(ctx context.Context, container *dagger.Container, packages []string) (map[string]string, error) {
+	out, err := container.
+		WithExec(append([]string{"apt-cache", "madison"}, packages...),
+			dagger.ContainerWithExecOpts{Expect: dagger.ReturnTypeAny}).
+		Stdout(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("while listing the versions of %s: %w", strings.Join(packages, ", "), err)
+	}
+
+	return newestPgdgVersions(parseAptCacheMadison(out)), nil
+}
+
+// parseAptCacheMadison parses the output of apt-cache madison, made of
+// "package | version | source" lines.
+func parseAptCacheMadison(output string) []madisonEntry {
+	var entries []madisonEntry
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Split(line, "|")
+		if len(fields) != 3 {
+			continue
+		}
+		entries = append(entries, madisonEntry{
+			Package: strings.TrimSpace(fields[0]),
+			Version: strings.TrimSpace(fields[1]),
+			Source:  strings.TrimSpace(fields[2]),
+		})
+	}
+
+	return entries
+}
+
+// newestPgdgVersions returns the newest version of each package coming from a
+// pgdg suite, following the Debian version ordering.
+func newestPgdgVersions(entries []madisonEntry) map[string]string {
+	versions := make(map[string]string)
+	for _, entry := range entries {
+		if !strings.Contains(entry.Source, "-pgdg") {
+			continue
+		}
+		if current, ok := versions[entry.Package]; !ok || compareDebianVersions(entry.Version, current) > 0 {
+			versions[entry.Package] = entry.Version
+		}
+	}
+
+	return versions
+}
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNewestPgdgVersions(t *testing.T) {
+	output := ` postgresql-19-pgvector | 0.8.1-2.pgdg13+1 | http://apt.postgresql.org/pub/repos/apt trixie-pgdg/main amd64 Packages
+ postgresql-19-pgvector | 0.8.10-1.pgdg13+1 | http://apt.postgresql.org/pub/repos/apt trixie-pgdg/main amd64 Packages
+ postgresql-19-pgvector | 0.9.0-1 | http://deb.debian.org/debian trixie/main amd64 Packages
+N: Unable to locate package postgresql-19-foo
+`
+	entries := parseAptCacheMadison(output)
+	if len(entries) != 3 {
+		t.Fatalf("got %d entries, want 3", len(entries))
+	}
+
+	got := newestPgdgVersions(entries)
+	want := map[string]string{"postgresql-19-pgvector": "0.8.10-1.pgdg13+1"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"maps"
+	"path"
+	"slices"
+	"strings"
+)
+
+const (
+	bitcodeDir = "bitcode"
+	// llvmJITProvider is the library PostgreSQL loads when jit is enabled
+	llvmJITProvider = "llvmjit.so"
+)
+
+// hasJITProvider reports whether a PostgreSQL image ships the LLVM JIT provider.
+func hasJITProvider(fsys *imageFS) bool {
+	return len(fsys.glob(path.Join("usr/lib/postgresql/*/lib", llvmJITProvider))) > 0
+}
+
+// bitcodeModules returns the set of modules for which an extension image ships
+// the LLVM bitcode used for JIT inlining (<lib>/bitcode/<module>.index.bc),
+// reporting the index files whose module directory is missing.
+func bitcodeModules(verification *imageVerification, fsys *imageFS, metadata *extensionMetadata) map[string]bool {
+	modules := make(map[string]bool)
+	for _, dir := range libraryDirectories(metadata) {
+		for _, name := range fsys.glob(path.Join(dir, bitcodeDir, "*.index.bc")) {
+			module := strings.TrimSuffix(path.Base(name), ".index.bc")
+			modules[module] = true
+			if !fsys.isDir(path.Join(dir, bitcodeDir, module)) {
+				verification.addProblem("%s: bitcode directory %s is missing",
+					name, path.Join(dir, bitcodeDir, module))
+			}
+		}
+	}
+
+	return modules
+}
+
+// loadableModules returns the set of loadable modules (<lib>/<module>.so)
+// installed in the library directories of an extension image.
+func loadableModules(fsys *imageFS, metadata *extensionMetadata) map[string]bool {
+	modules := make(map[string]bool)
+	for _, dir := range libraryDirectories(metadata) {
+		for _, name := range fsys.glob(path.Join(dir, "*.so")) {
+			modules[strings.TrimSuffix(path.Base(name), ".so")] = true
+		}
+	}
+
+	return modules
+}
+
+// packageBitcodeModules returns the set of modules whose bitcode is shipped by
+// the Debian packages of an extension, given the files they ship. Builds
+// without PGXS, such as CMake ones, emit no bitcode.
+func packageBitcodeModules(files []string) map[string]bool {
+	modules := make(map[string]bool)
+	for _, name := range files {
+		if ok, _ := path.Match(path.Join("/usr/lib/postgresql/*/lib", bitcodeDir, "*.index.bc"), name); ok {
+			modules[strings.TrimSuffix(path.Base(name), ".index.bc")] = true
+		}
+	}
+
+	return modules
+}
+
+// verifyBitcodeCompleteness checks that every platform whose base image has JIT
+// available ships the bitcode of the modules which evidently support JIT
+// inlining: the modules it installs whose bitcode is shipped by the Debian
+// packages, and the modules whose bitcode is shipped on any other platform,
+// since the build of a module is JIT-capable regardless of the architecture.
+// The bitcode and modules map each platform to the sets returned by
+// bitcodeModules and loadableModules, packageBitcode is the set returned by
+// packageBitcodeModules.
+func verifyBitcodeCompleteness(
+	verifications []*imageVerification,
+	bitcode map[string]map[string]bool,
+	modules map[string]map[string]bool,
+	packageBitcode map[string]bool,
+	jitPlatforms map[string]bool,
+) {
+	for _, verification := range verifications {
+		if !jitPlatforms[verification.Platform] {
+			continue
+		}
+		shipped := bitcode[verification.Platform]
+
+		var fromPackage []string
+		for _, module := range slices.Sorted(maps.Keys(modules[verification.Platform])) {
+			if packageBitcode[module] && !shipped[module] {
+				fromPackage = append(fromPackage, module)
+				verification.addProblem("bitcode for module %s is missing, while shipped by its package", module)
+			}
+		}
+
+		var missing []string
+		for platform, platformModules := range bitcode {
+			if platform == verification.Platform {
+				continue
+			}
+			for module := range platformModules {
+				if !shipped[module] && !slices.Contains(fromPackage, module) && !slices.Contains(missing, module) {
+					missing = append(missing, module)
+				}
+			}
+		}
+		slices.Sort(missing)
+
+		for _, module := range missing {
+			verification.addProblem("bitcode for module %s is missing, while shipped on other platforms", module)
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestHasJITProvider(t *testing.T) {
+	withJIT := newTestImageFS(t, map[string]string{
+		"usr/lib/postgresql/18/lib/llvmjit.so": "",
+	})
+	if !hasJITProvider(withJIT) {
+		t.Error("expected the JIT provider to be found")
+	}
+
+	withoutJIT := newTestImageFS(t, map[string]string{
+		"usr/lib/postgresql/18/lib/plpgsql.so": "",
+	})
+	if hasJITProvider(withoutJIT) {
+		t.Error("expected no JIT provider")
+	}
+}
+
+func TestBitcodeModules(t *testing.T) {
+	fsys := newTestImageFS(t, map[string]string{
+		"lib/vector.so":                      "",
+		"lib/bitcode/vector.index.bc":        "",
+		"lib/bitcode/vector/src/vector.bc":   "",
+		"lib/bitcode/postgis-3.index.bc":     "",
+		"share/extension/vector--0.8.6.sql":  "",
+		"lib/bitcode/vector/src/halfvec.bc":  "",
+		"lib/bitcode/not-an-index/module.bc": "",
+	})
+
+	verification := &imageVerification{Platform: "linux/amd64"}
+	modules := bitcodeModules(verification, fsys, &extensionMetadata{})
+
+	if !modules["vector"] || !modules["postgis-3"] || len(modules) != 2 {
+		t.Errorf("bitcodeModules() = %v, want vector and postgis-3", modules)
+	}
+	if len(verification.Problems) != 1 {
+		t.Errorf("got problems %v, want the missing postgis-3 directory", verification.Problems)
+	}
+}
+
+func TestLoadableModules(t *testing.T) {
+	fsys := newTestImageFS(t, map[string]string{
+		"lib/vector.so":               "",
+		"lib/bitcode/vector.index.bc": "",
+		"lib/system/libgeos.so":       "",
+		"lib/libgeos_c.so.1":          "",
+		"share/extension/vector.so":   "",
+	})
+
+	modules := loadableModules(fsys, &extensionMetadata{})
+	if !modules["vector"] || len(modules) != 1 {
+		t.Errorf("loadableModules() = %v, want vector", modules)
+	}
+}
+
+func TestPackageBitcodeModules(t *testing.T) {
+	modules := packageBitcodeModules([]string{
+		"/usr/lib/postgresql/18/lib/vector.so",
+		"/usr/lib/postgresql/18/lib/bitcode/vector.index.bc",
+		"/usr/lib/postgresql/18/lib/bitcode/vector/src/vector.bc",
+		"/usr/share/postgresql/18/extension/vector.control",
+	})
+	if !modules["vector"] || len(modules) != 1 {
+		t.Errorf("packageBitcodeModules() = %v, want vector", modules)
+	}
+}
+
+func TestVerifyBitcodeCompleteness(t *testing.T) {
+	amd64 := &imageVerification{Platform: "linux/amd64"}
+	arm64 := &imageVerification{Platform: "linux/arm64"}
+	bitcode := map[string]map[string]bool{
+		"linux/amd64": {"vector": true, "postgis-3": true},
+		"linux/arm64": {"postgis-3": true},
+	}
+	modules := map[string]map[string]bool{
+		"linux/amd64": {"vector": true, "postgis-3": true},
+		"linux/arm64": {"vector": true, "postgis-3": true},
+	}
+	jitPlatforms := map[string]bool{"linux/amd64": true, "linux/arm64": true}
+
+	verifyBitcodeCompleteness([]*imageVerification{amd64, arm64}, bitcode, modules, map[string]bool{}, jitPlatforms)
+
+	if len(amd64.Problems) != 0 {
+		t.Errorf("linux/amd64: unexpected problems %v", amd64.Problems)
+	}
+	want := []string{"bitcode for module vector is missing, while shipped on other platforms"}
+	if !slices.Equal(arm64.Problems, want) {
+		t.Errorf("linux/arm64: got problems %v, want %v", arm64.Problems, want)
+	}
+
+	withoutJIT := &imageVerification{Platform: "linux/arm64"}
+	verifyBitcodeCompleteness([]*imageVerification{withoutJIT}, bitcode, modules, map[string]bool{}, map[string]bool{})
+	if len(withoutJIT.Problems) != 0 {
+		t.Errorf("without JIT: unexpected problems %v", withoutJIT.Problems)
+	}
+}
+
+func TestVerifyBitcodeCompletenessWithoutBitcode(t *testing.T) {
+	amd64 := &imageVerification{Platform: "linux/amd64"}
+	arm64 := &imageVerification{Platform: "linux/arm64"}
+	modules := map[string]map[string]bool{
+		"linux/amd64": {"vector": true},
+		"linux/arm64": {"vector": true},
+	}
+	bitcode := map[string]map[string]bool{
+		"linux/amd64": {},
+		"linux/arm64": {},
+	}
+
+	verifyBitcodeCompleteness([]*imageVerification{amd64, arm64}, bitcode, modules,
+		map[string]bool{"vector": true}, map[string]bool{"linux/amd64": true, "linux/arm64": true})
+
+	want := []string{"bitcode for module vector is missing, while shipped by its package"}
+	for _, verification := range []*imageVerification{amd64, arm64} {
+		if !slices.Equal(verification.Problems, want) {
+			t.Errorf("%s: got problems %v, want %v", verification.Platform, verification.Problems, want)
+		}
+	}
+}
+
+func TestVerifyBitcodeCompletenessCMakeBuild(t *testing.T) {
+	// CMake builds, such as timescaledb, install modules without any bitcode
+	fsys := newTestImageFS(t, map[string]string{
+		"lib/timescaledb.so":        "",
+		"lib/timescaledb-2.22.0.so": "",
+	})
+	metadata := &extensionMetadata{}
+
+	var verifications []*imageVerification
+	bitcode := make(map[string]map[string]bool)
+	modules := make(map[string]map[string]bool)
+	for _, platform := range []string{"linux/amd64", "linux/arm64"} {
+		verification := &imageVerification{Platform: platform}
+		bitcode[platform] = bitcodeModules(verification, fsys, metadata)
+		modules[platform] = loadableModules(fsys, metadata)
+		verifications = append(verifications, verification)
+	}
+	packageBitcode := packageBitcodeModules([]string{
+		"/usr/lib/postgresql/18/lib/timescaledb.so",
+		"/usr/lib/postgresql/18/lib/timescaledb-2.22.0.so",
+	})
+
+	verifyBitcodeCompleteness(verifications, bitcode, modules, packageBitcode,
+		map[string]bool{"linux/amd64": true, "linux/arm64": true})
+
+	for _, verification := range verifications {
+		if len(verification.Problems) != 0 {
+			t.Errorf("%s: unexpected problems %v", verification.Platform, verification.Problems)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"dagger/maintenance/internal/dagger"
+)
+
+// VersionBumps holds the version changes applied by BumpVersions
+type VersionBumps struct {
+	// The summary of the version changes, in Markdown
+	Summary string
+	// The changes to the metadata.hcl files
+	Changes *dagger.Changeset
+}
+
+// versionBump is a package version change of a distribution and PG major.
+type versionBump struct {
+	Extension    string
+	Distribution string
+	PgMajor      string
+	Package      string
+	FromVersion  string
+	ToVersion    string
+	FromSQL      string
+	ToSQL        string
+}
+
+// bumpExtensionVersions sets the package version of every distribution and
+// PG major of an extension to the newest one available in the pgdg repository
+// of the matching base image, deriving the sql version with the extractVersion
+// rule of its renovate annotation, as renovate does.
+func bumpExtensionVersions(
+	ctx context.Context,
+	document *metadataDocument,
+	metadata *extensionMetadata,
+	mirror string,
+) ([]versionBump, error) {
+	entries, err := document.versionEntries()
+	if err != nil {
+		return nil, err
+	}
+
+	var bumps []versionBump
+	for _, entry := range entries {
+		current, ok := document.attributeValue(entry, "package")
+		if !ok {
+			return nil, fmt.Errorf("no package version for PostgreSQL %s on %s", entry.MajorVersion, entry.Distribution)
+		}
+
+		packageName := ""
+		if annotation := entry.Annotations["package"]; annotation != nil {
+			packageName = annotation.DepName
+		}
+		if packageName == "" {
+			packages, err := resolvePackages(metadata, entry.MajorVersion)
+			if err != nil {
+				return nil, err
+			}
+			packageName = packages[0]
+		}
+
+		container := pgdgContainer(entry.Distribution, entry.MajorVersion, mirror)
+		versions, err := getPgdgVersions(ctx, container, []string{packageName})
+		if err != nil {
+			return nil, err
+		}
+		latest, ok := versions[packageName]
+		if !ok {
+			return nil, fmt.Errorf("package %s not found in the pgdg repository for %s",
+				packageName, entry.Distribution)
+		}
+		if compareDebianVersions(latest, current) <= 0 {
+			continue
+		}
+
+		bump := versionBump{
+			Extension:    metadata.Name,
+			Distribution: entry.Distribution,
+			PgMajor:      entry.MajorVersion,
+			Package:      packageName,
+			FromVersion:  current,
+			ToVersion:    latest,
+		}
+		if err := document.setAttribute(entry.Distribution, entry.MajorVersion, "package", latest); err != nil {
+			return nil, err
+		}
+
+		if sql, ok := document.attributeValue(entry, "sql"); ok {
+			annotation := entry.Annotations["sql"]
+			if annotation == nil || annotation.ExtractVersion == "" {
+				return nil, fmt.Errorf("no extractVersion renovate annotation for the sql version of PostgreSQL %s on %s",
+					entry.MajorVersion, entry.Distribution)
+			}
+			bump.FromSQL = sql
+			bump.ToSQL, err = extractVersion(annotation.ExtractVersion, latest)
+			if err != nil {
+				return nil, err
+			}
+			if err := document.setAttribute(entry.Distribution, entry.MajorVersion, "sql", bump.ToSQL); err != nil {
+				return nil, err
+			}
+		}
+		bumps = append(bumps, bump)
+	}
+
+	return bumps, nil
+}
+
+// formatVersionBumps renders the version changes in Markdown.
+func formatVersionBumps(bumps []versionBump) string {
+	if len(bumps) == 0 {
+		return "No version changes.\n"
+	}
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "| Extension | Distribution | PostgreSQL | Package | From | To | SQL |\n")
+	fmt.Fprintf(&out, "|---|---|---|---|---|---|---|\n")
+	for _, bump := range bumps {
+		sql := ""
+		if bump.FromSQL != bump.ToSQL {
+			sql = fmt.Sprintf("%s → %s", bump.FromSQL, bump.ToSQL)
+		}
+		fmt.Fprintf(&out, "| %s | %s | %s | `%s` | %s | %s | %s |\n",
+			bump.Extension, bump.Distribution, bump.PgMajor, bump.Package, bump.FromVersion, bump.ToVersion, sql)
+	}
+
+	return out.String()
+}
@@ -0,0 +1,39 @@
+package main
+
+import "testing"
+
+func TestFormatVersionBumps(t *testing.T) {
+	if got, want := formatVersionBumps(nil), "No version changes.\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	got := formatVersionBumps([]versionBump{
+		{
+			Extension:    "pgvector",
+			Distribution: "bookworm",
+			PgMajor:      "18",
+			Package:      "postgresql-18-pgvector",
+			FromVersion:  "0.8.1-2.pgdg12+1",
+			ToVersion:    "0.8.2-1.pgdg12+1",
+			FromSQL:      "0.8.1",
+			ToSQL:        "0.8.2",
+		},
+		{
+			Extension:    "pgvector",
+			Distribution: "trixie",
+			PgMajor:      "18",
+			Package:      "postgresql-18-pgvector",
+			FromVersion:  "0.8.1-2.pgdg13+1",
+			ToVersion:    "0.8.1-3.pgdg13+1",
+			FromSQL:      "0.8.1",
+			ToSQL:        "0.8.1",
+		},
+	})
+	want := "| Extension | Distribution | PostgreSQL | Package | From | To | SQL |\n" +
+		"|---|---|---|---|---|---|---|\n" +
+		"| pgvector | bookworm | 18 | `postgresql-18-pgvector` | 0.8.1-2.pgdg12+1 | 0.8.2-1.pgdg12+1 | 0.8.1 → 0.8.2 |\n" +
+		"| pgvector | trixie | 18 | `postgresql-18-pgvector` | 0.8.1-2.pgdg13+1 | 0.8.1-3.pgdg13+1 |  |\n"
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+
+	containerregistryv1 "github.com/google/go-containerregistry/pkg/v1"
+	ocispecv1 "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+const (
+	// indexLevel is used in place of a platform to report problems of the image index
+	indexLevel = "index"
+
+	annotationReferenceType   = "vnd.docker.reference.type"
+	annotationReferenceDigest = "vnd.docker.reference.digest"
+	annotationPredicateType   = "in-toto.io/predicate-type"
+
+	attestationManifestType = "attestation-manifest"
+	predicateTypeSBOM       = "https://spdx.dev/Document"
+	predicateTypeProvenance = "https://slsa.dev/provenance/"
+)
+
+// requiredPlatforms are the platforms every extension image is built for,
+// as declared in docker-bake.hcl.
+var requiredPlatforms = []string{
+	"linux/amd64",
+	"linux/arm64",
+}
+
+// requiredAnnotations are the annotations docker-bake.hcl sets at both
+// the index and the manifest level of every extension image.
+var requiredAnnotations = []string{
+	ocispecv1.AnnotationCreated,
+	ocispecv1.AnnotationURL,
+	ocispecv1.AnnotationSource,
+	ocispecv1.AnnotationVersion,
+	ocispecv1.AnnotationRevision,
+	ocispecv1.AnnotationVendor,
+	ocispecv1.AnnotationTitle,
+	ocispecv1.AnnotationDescription,
+	ocispecv1.AnnotationDocumentation,
+	ocispecv1.AnnotationAuthors,
+	ocispecv1.AnnotationLicenses,
+	ocispecv1.AnnotationBaseImageName,
+	AnnotationImageBaseName,
+	AnnotationImageBasePgMajor,
+	AnnotationImageBaseOS,
+	AnnotationImageSQLVersion,
+}
+
+// imageVersionRegex mirrors the getExtensionVersion function of docker-bake.hcl.
+var imageVersionRegex = regexp.MustCompile(`^(?:[0-9]+:)?[0-9]+(?:\.[0-9]+)*`)
+
+// imageVersionFromPackage returns the image version computed by docker-bake.hcl
+// for a package version (e.g. 0.8.1-2.pgdg13+1 -> 0.8.1, 1:6.1.0-2.pgdg13+1 -> 1-6.1.0).
+func imageVersionFromPackage(packageVersion string) string {
+	return strings.ReplaceAll(imageVersionRegex.FindString(packageVersion), ":", "-")
+}
+
+// expectedAnnotations returns the values of the annotations derived from the
+// metadata of an extension, for a given version.
+func expectedAnnotations(metadata *extensionMetadata, version extensionVersion) map[string]string {
+	return map[string]string{
+		ocispecv1.AnnotationVersion:  imageVersionFromPackage(version.Package),
+		ocispecv1.AnnotationLicenses: strings.Join(metadata.Licenses, " AND "),
+		AnnotationImageSQLVersion:    version.SQL,
+	}
+}
+
+// verifyIndexConformance checks that an image index holds every required
+// platform, that the required annotations are set at both the index and the
+// manifest level with the expected values, and that SBOM and provenance
+// attestations are attached to every platform manifest.
+// The manifests map holds the manifests referenced by the index, keyed by digest.
+func verifyIndexConformance(
+	index *containerregistryv1.IndexManifest,
+	manifests map[string]*containerregistryv1.Manifest,
+	expected map[string]string,
+) []*imageVerification {
+	indexVerification := &imageVerification{Platform: indexLevel}
+	verifications := []*imageVerification{indexVerification}
+	verifyAnnotations(indexVerification, index.Annotations, expected)
+
+	attestations := make(map[string]*containerregistryv1.Manifest)
+	for _, descriptor := range index.Manifests {
+		if descriptor.Annotations[annotationReferenceType] == attestationManifestType {
+			attestations[descriptor.Annotations[annotationReferenceDigest]] = manifests[descriptor.Digest.String()]
+		}
+	}
+
+	var platforms []string
+	for _, descriptor := range index.Manifests {
+		if descriptor.Platform == nil || descriptor.Platform.OS == "unknown" {
+			continue
+		}
+		platform := formatPlatform(descriptor.Platform)
+		platforms = append(platforms, platform)
+
+		verification := &imageVerification{Platform: platform}
+		verifications = append(verifications, verification)
+
+		manifest, ok := manifests[descriptor.Digest.String()]
+		if !ok {
+			verification.addProblem("manifest %s not found", descriptor.Digest)
+			continue
+		}
+		verifyAnnotations(verification, manifest.Annotations, expected)
+
+		attestation, ok := attestations[descriptor.Digest.String()]
+		if !ok || attestation == nil {
+			verification.addProblem("no attestation manifest attached")
+			continue
+		}
+		if !hasPredicate(attestation, predicateTypeSBOM) {
+			verification.addProblem("no SBOM attestation attached")
+		}
+		if !hasPredicate(attestation, predicateTypeProvenance) {
+			verification.addProblem("no provenance attestation attached")
+		}
+	}
+
+	for _, platform := range requiredPlatforms {
+		if !slices.Contains(platforms, platform) {
+			indexVerification.addProblem("platform %s is missing", platform)
+		}
+	}
+
+	return verifications
+}
+
+// verifyAnnotations checks that the required annotations are set, and that the
+// ones derived from the metadata have the expected values.
+func verifyAnnotations(verification *imageVerification, annotations map[string]string, expected map[string]string) {
+	for _, key := range requiredAnnotations {
+		value, ok := annotations[key]
+		if !ok {
+			verification.addProblem("annotation %q is missing", key)
+			continue
+		}
+		if want, ok := expected[key]; ok && value != want {
+			verification.addProblem("annotation %q is %q, expected %q", key, value, want)
+		}
+	}
+}
+
+// hasPredicate reports whether an attestation manifest holds a layer with the
+// given in-toto predicate type (or predicate type prefix).
+func hasPredicate(attestation *containerregistryv1.Manifest, predicateType string) bool {
+	return slices.ContainsFunc(attestation.Layers, func(layer containerregistryv1.Descriptor) bool {
+		return strings.HasPrefix(layer.Annotations[annotationPredicateType], predicateType)
+	})
+}
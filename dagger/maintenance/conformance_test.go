@@ -0,0 +1,161 @@
+package main
+
+import (
+	"maps"
+	"strings"
+	"testing"
+
+	containerregistryv1 "github.com/google/go-containerregistry/pkg/v1"
+	ocispecv1 "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+func TestImageVersionFromPackage(t *testing.T) {
+	cases := map[string]string{
+		"0.8.1-2.pgdg13+1":      "0.8.1",
+		"3.6.4+dfsg-2.pgdg12+1": "3.6.4",
+		"0.3-2.pgdg13+1":        "0.3",
+		"1:6.1.0-2.pgdg13+1":    "1-6.1.0",
+	}
+	for packageVersion, want := range cases {
+		if got := imageVersionFromPackage(packageVersion); got != want {
+			t.Errorf("imageVersionFromPackage(%q) = %q, want %q", packageVersion, got, want)
+		}
+	}
+}
+
+func TestVerifyIndexConformance(t *testing.T) {
+	metadata := &extensionMetadata{Licenses: []string{"Apache-2.0", "PostgreSQL"}}
+	expected := expectedAnnotations(metadata, extensionVersion{Package: "2.29.2+dfsg-1.pgdg13+1", SQL: "2.29.2"})
+
+	validAnnotations := func() map[string]string {
+		annotations := make(map[string]string, len(requiredAnnotations))
+		for _, key := range requiredAnnotations {
+			annotations[key] = "value"
+		}
+		annotations[ocispecv1.AnnotationRevision] = ""
+		maps.Copy(annotations, expected)
+		return annotations
+	}
+
+	digest := func(hex string) containerregistryv1.Hash {
+		return containerregistryv1.Hash{Algorithm: "sha256", Hex: strings.Repeat(hex, 64)}
+	}
+	platformDescriptor := func(hex, arch string) containerregistryv1.Descriptor {
+		return containerregistryv1.Descriptor{
+			Digest:   digest(hex),
+			Platform: &containerregistryv1.Platform{OS: "linux", Architecture: arch},
+		}
+	}
+	attestationDescriptor := func(hex, ref string) containerregistryv1.Descriptor {
+		return containerregistryv1.Descriptor{
+			Digest:   digest(hex),
+			Platform: &containerregistryv1.Platform{OS: "unknown", Architecture: "unknown"},
+			Annotations: map[string]string{
+				annotationReferenceType:   attestationManifestType,
+				annotationReferenceDigest: digest(ref).String(),
+			},
+		}
+	}
+	attestationManifest := func(predicateTypes ...string) *containerregistryv1.Manifest {
+		manifest := &containerregistryv1.Manifest{}
+		for _, predicateType := range predicateTypes {
+			manifest.Layers = append(manifest.Layers, containerregistryv1.Descriptor{
+				Annotations: map[string]string{annotationPredicateType: predicateType},
+			})
+		}
+		return manifest
+	}
+
+	newImage := func() (*containerregistryv1.IndexManifest, map[string]*containerregistryv1.Manifest) {
+		index := &containerregistryv1.IndexManifest{
+			Annotations: validAnnotations(),
+			Manifests: []containerregistryv1.Descriptor{
+				platformDescriptor("a", "amd64"),
+				platformDescriptor("b", "arm64"),
+				attestationDescriptor("c", "a"),
+				attestationDescriptor("d", "b"),
+			},
+		}
+		manifests := map[string]*containerregistryv1.Manifest{
+			digest("a").String(): {Annotations: validAnnotations()},
+			digest("b").String(): {Annotations: validAnnotations()},
+			digest("c").String(): attestationManifest("https://slsa.dev/provenance/v0.2", predicateTypeSBOM),
+			digest("d").String(): attestationManifest("https://slsa.dev/provenance/v1", predicateTypeSBOM),
+		}
+		return index, manifests
+	}
+
+	tests := []struct {
+		name         string
+		mutate       func(*containerregistryv1.IndexManifest, map[string]*containerregistryv1.Manifest)
+		wantProblems map[string][]string
+	}{
+		{
+			name:   "conformant image",
+			mutate: func(*containerregistryv1.IndexManifest, map[string]*containerregistryv1.Manifest) {},
+		},
+		{
+			name: "missing arm64 platform",
+			mutate: func(index *containerregistryv1.IndexManifest, _ map[string]*containerregistryv1.Manifest) {
+				index.Manifests = []containerregistryv1.Descriptor{index.Manifests[0], index.Manifests[2]}
+			},
+			wantProblems: map[string][]string{
+				indexLevel: {"platform linux/arm64 is missing"},
+			},
+		},
+		{
+			name: "missing manifest annotation",
+			mutate: func(_ *containerregistryv1.IndexManifest, manifests map[string]*containerregistryv1.Manifest) {
+				delete(manifests[digest("b").String()].Annotations, ocispecv1.AnnotationTitle)
+			},
+			wantProblems: map[string][]string{
+				"linux/arm64": {`annotation "org.opencontainers.image.title" is missing`},
+			},
+		},
+		{
+			name: "wrong versions and licenses",
+			mutate: func(index *containerregistryv1.IndexManifest, manifests map[string]*containerregistryv1.Manifest) {
+				index.Annotations[ocispecv1.AnnotationLicenses] = "Apache-2.0"
+				manifests[digest("a").String()].Annotations[ocispecv1.AnnotationVersion] = "2.29.1"
+				manifests[digest("a").String()].Annotations[AnnotationImageSQLVersion] = "2.29.1"
+			},
+			wantProblems: map[string][]string{
+				indexLevel: {`annotation "org.opencontainers.image.licenses" is "Apache-2.0", expected "Apache-2.0 AND PostgreSQL"`},
+				"linux/amd64": {
+					`annotation "org.opencontainers.image.version" is "2.29.1", expected "2.29.2"`,
+					`annotation "io.cloudnativepg.image.sql.version" is "2.29.1", expected "2.29.2"`,
+				},
+			},
+		},
+		{
+			name: "missing attestations",
+			mutate: func(index *containerregistryv1.IndexManifest, manifests map[string]*containerregistryv1.Manifest) {
+				index.Manifests = index.Manifests[:3]
+				manifests[digest("c").String()] = attestationManifest(predicateTypeSBOM)
+			},
+			wantProblems: map[string][]string{
+				"linux/amd64": {"no provenance attestation attached"},
+				"linux/arm64": {"no attestation manifest attached"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			index, manifests := newImage()
+			tt.mutate(index, manifests)
+
+			for _, verification := range verifyIndexConformance(index, manifests, expected) {
+				want := tt.wantProblems[verification.Platform]
+				if len(verification.Problems) != len(want) {
+					t.Fatalf("%s: got problems %q, want %q", verification.Platform, verification.Problems, want)
+				}
+				for i := range want {
+					if verification.Problems[i] != want[i] {
+						t.Errorf("%s: problem %d: got %q, want %q", verification.Platform, i, verification.Problems[i], want[i])
+					}
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+)
+
+// baseExtensionControlFiles matches the control files of the extensions
+// shipped with PostgreSQL in a base image
+const baseExtensionControlFiles = "usr/share/postgresql/*/extension/*.control"
+
+// baseImageExtensions returns the SQL names of the extensions shipped with
+// PostgreSQL in a base image, which don't need to be declared in
+// required_extensions.
+func baseImageExtensions(fsys *imageFS) []string {
+	var sqlNames []string
+	for _, name := range fsys.glob(baseExtensionControlFiles) {
+		sqlNames = append(sqlNames, strings.TrimSuffix(path.Base(name), ".control"))
+	}
+
+	return sqlNames
+}
+
+// ExtensionControl holds the properties declared in an extension's control file
+type ExtensionControl struct {
+	// The name of the extension, as used in CREATE EXTENSION
+	Name string
+	// The default version installed by CREATE EXTENSION
+	DefaultVersion string
+	// The extensions which must be installed before this one
+	Requires []string
+	// Whether the extension can be installed by non-superusers with the CREATE privilege
+	Trusted bool
+	// Whether the extension requires superuser privileges to be installed
+	Superuser bool
+	// Whether the extension objects can be moved to another schema
+	Relocatable bool
+}
+
+// newExtensionControl builds the properties of an extension from the
+// parameters of its control file, applying the PostgreSQL defaults.
+func newExtensionControl(name string, params map[string]string) (*ExtensionControl, error) {
+	control := &ExtensionControl{
+		Name:           name,
+		DefaultVersion: params["default_version"],
+		Superuser:      true,
+	}
+
+	for _, required := range strings.Split(params["requires"], ",") {
+		required = strings.Trim(strings.TrimSpace(required), `"`)
+		if required != "" {
+			control.Requires = append(control.Requires, required)
+		}
+	}
+
+	for key, field := range map[string]*bool{
+		"trusted":     &control.Trusted,
+		"superuser":   &control.Superuser,
+		"relocatable": &control.Relocatable,
+	} {
+		value, ok := params[key]
+		if !ok {
+			continue
+		}
+		parsed, err := parseControlBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("parameter %q: %w", key, err)
+		}
+		*field = parsed
+	}
+
+	return control, nil
+}
+
+// parseControlBool parses a boolean control file parameter, accepting the
+// same spellings as PostgreSQL (including unique prefixes).
+func parseControlBool(value string) (bool, error) {
+	lowered := strings.ToLower(value)
+	isPrefixOf := func(word string, minLength int) bool {
+		return len(lowered) >= minLength && strings.HasPrefix(word, lowered)
+	}
+
+	switch {
+	case isPrefixOf("true", 1), isPrefixOf("yes", 1), isPrefixOf("on", 2), lowered == "1":
+		return true, nil
+	case isPrefixOf("false", 1), isPrefixOf("no", 1), isPrefixOf("off", 2), lowered == "0":
+		return false, nil
+	}
+
+	return false, fmt.Errorf("invalid boolean value %q", value)
+}
+
+// verifyExtensionControl compares the properties declared in the control file
+// with the metadata of the extension. The requiredSQLNames are the SQL names
+// of the extensions listed in required_extensions, the bundledSQLNames are the
+// ones whose control file is shipped in the image itself, and the
+// builtinSQLNames the ones shipped with PostgreSQL in the base image. The
+// properties the metadata doesn't declare are reported as notes.
+func verifyExtensionControl(
+	verification *imageVerification,
+	control *ExtensionControl,
+	metadata *extensionMetadata,
+	requiredSQLNames []string,
+	bundledSQLNames []string,
+	builtinSQLNames []string,
+) {
+	for _, required := range control.Requires {
+		if !slices.Contains(requiredSQLNames, required) &&
+			!slices.Contains(bundledSQLNames, required) &&
+			!slices.Contains(builtinSQLNames, required) {
+			verification.addProblem("control file requires %q, which is missing from required_extensions", required)
+		}
+	}
+	for _, required := range requiredSQLNames {
+		if !slices.Contains(control.Requires, required) {
+			verification.addProblem("required extension %q is not in the requires of the control file", required)
+		}
+	}
+
+	for _, property := range []struct {
+		name     string
+		declared *bool
+		actual   bool
+	}{
+		{name: "trusted", declared: metadata.Trusted, actual: control.Trusted},
+		{name: "superuser", declared: metadata.Superuser, actual: control.Superuser},
+		{name: "relocatable", declared: metadata.Relocatable, actual: control.Relocatable},
+	} {
+		switch {
+		case property.declared == nil:
+			verification.addNote("control file sets %s = %t, not declared in the metadata",
+				property.name, property.actual)
+		case *property.declared != property.actual:
+			verification.addProblem("control file sets %s = %t, while the metadata declares %t",
+				property.name, property.actual, *property.declared)
+		}
+	}
+}
+
+// parseControlFile parses the content of an extension's control file,
+// returning its parameters. The file follows the postgresql.conf syntax:
+// one "name = value" per line, where the "=" is optional, values can be
+// single-quoted and "#" starts a comment.
+func parseControlFile(content string) (map[string]string, error) {
+	params := make(map[string]string)
+
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		end := strings.IndexFunc(line, func(r rune) bool {
+			return r == '=' || r == ' ' || r == '\t'
+		})
+		if end <= 0 {
+			return nil, fmt.Errorf("line %d: missing value for parameter %q", lineNumber, line)
+		}
+		key := line[:end]
+
+		rest := strings.TrimSpace(line[end:])
+		rest = strings.TrimSpace(strings.TrimPrefix(rest, "="))
+
+		value, err := parseControlValue(rest)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: parameter %q: %w", lineNumber, key, err)
+		}
+		params[key] = value
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return params, nil
+}
+
+// parseControlValue parses the value of a control file parameter, which is
+// either a single-quoted string or an unquoted word, optionally followed by
+// a comment.
+func parseControlValue(raw string) (string, error) {
+	if raw == "" || strings.HasPrefix(raw, "#") {
+		return "", fmt.Errorf("missing value")
+	}
+
+	if raw[0] != '\'' {
+		word, _, _ := strings.Cut(raw, "#")
+		word = strings.TrimSpace(word)
+		if strings.ContainsAny(word, " \t") {
+			return "", fmt.Errorf("unexpected characters after value %q", word)
+		}
+		return word, nil
+	}
+
+	var value strings.Builder
+	for i := 1; i < len(raw); i++ {
+		switch {
+		case raw[i] == '\\' && i+1 < len(raw):
+			i++
+			value.WriteByte(raw[i])
+		case raw[i] == '\'' && i+1 < len(raw) && raw[i+1] == '\'':
+			i++
+			value.WriteByte('\'')
+		case raw[i] == '\'':
+			trailing := strings.TrimSpace(raw[i+1:])
+			if trailing != "" && !strings.HasPrefix(trailing, "#") {
+				return "", fmt.Errorf("unexpected characters after value %q", trailing)
+			}
+			return value.String(), nil
+		default:
+			value.WriteByte(raw[i])
+		}
+	}
+
+	return "", fmt.Errorf("unterminated quoted string")
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"maps"
+	"reflect"
+	"slices"
+	"testing"
+)
+
+func TestParseControlFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    map[string]string
+		wantErr bool
+	}{
+		{
+			name: "pgvector control file",
+			content: `comment = 'vector data type and ivfflat and hnsw access methods'
+default_version = '0.8.1'
+module_pathname = '$libdir/vector'
+relocatable = true
+`,
+			want: map[string]string{
+				"comment":         "vector data type and ivfflat and hnsw access methods",
+				"default_version": "0.8.1",
+				"module_pathname": "$libdir/vector",
+				"relocatable":     "true",
+			},
+		},
+		{
+			name: "comments, blank lines and optional equal sign",
+			content: `# postgis extension
+# comment on its own line
+
+default_version '3.6.4'
+relocatable = false # trailing comment
+requires = 'postgis, plpgsql'   # list of extensions
+`,
+			want: map[string]string{
+				"default_version": "3.6.4",
+				"relocatable":     "false",
+				"requires":        "postgis, plpgsql",
+			},
+		},
+		{
+			name:    "escaped quotes",
+			content: `comment = 'it''s an \'extension\''`,
+			want: map[string]string{
+				"comment": "it's an 'extension'",
+			},
+		},
+		{
+			name:    "unterminated string",
+			content: `comment = 'oops`,
+			wantErr: true,
+		},
+		{
+			name:    "missing value",
+			content: `relocatable =`,
+			wantErr: true,
+		},
+		{
+			name:    "garbage after value",
+			content: `default_version = '1.0' extra`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseControlFile(tt.content)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !maps.Equal(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewExtensionControl(t *testing.T) {
+	control, err := newExtensionControl("pgrouting", map[string]string{
+		"default_version": "3.8.0",
+		"requires":        `postgis, "plpgsql"`,
+		"trusted":         "on",
+		"relocatable":     "f",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := &ExtensionControl{
+		Name:           "pgrouting",
+		DefaultVersion: "3.8.0",
+		Requires:       []string{"postgis", "plpgsql"},
+		Trusted:        true,
+		Superuser:      true,
+		Relocatable:    false,
+	}
+	if !reflect.DeepEqual(control, want) {
+		t.Errorf("newExtensionControl() = %+v, want %+v", control, want)
+	}
+
+	if _, err := newExtensionControl("vector", map[string]string{"trusted": "maybe"}); err == nil {
+		t.Error("newExtensionControl() with an invalid boolean: expected an error")
+	}
+}
+
+func TestParseControlBool(t *testing.T) {
+	for value, want := range map[string]bool{
+		"true": true, "TRUE": true, "t": true, "yes": true, "on": true, "1": true,
+		"false": false, "f": false, "no": false, "off": false, "of": false, "0": false,
+	} {
+		got, err := parseControlBool(value)
+		if err != nil {
+			t.Errorf("parseControlBool(%q): unexpected error: %v", value, err)
+			continue
+		}
+		if got != want {
+			t.Errorf("parseControlBool(%q) = %v, want %v", value, got, want)
+		}
+	}
+
+	for _, value := range []string{"", "o", "2", "yess"} {
+		if _, err := parseControlBool(value); err == nil {
+			t.Errorf("parseControlBool(%q): expected an error", value)
+		}
+	}
+}
+
+func TestBaseImageExtensions(t *testing.T) {
+	fsys := newTestImageFS(t, map[string]string{
+		"usr/share/postgresql/18/extension/plpgsql.control":            "",
+		"usr/share/postgresql/18/extension/plpgsql--1.0.sql":           "",
+		"usr/share/postgresql/18/extension/pg_stat_statements.control": "",
+		"usr/share/postgresql/18/tsearch_data/english.stop":            "",
+	})
+
+	got := baseImageExtensions(fsys)
+	slices.Sort(got)
+	if want := []string{"pg_stat_statements", "plpgsql"}; !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestVerifyExtensionControl(t *testing.T) {
+	trusted := true
+	control := &ExtensionControl{
+		Name:      "pgrouting",
+		Requires:  []string{"postgis", "plpgsql", "pgrouting_extra"},
+		Superuser: true,
+	}
+
+	tests := []struct {
+		name     string
+		metadata *extensionMetadata
+		required []string
+		builtin  []string
+		want     int
+	}{
+		{
+			name:     "consistent",
+			metadata: &extensionMetadata{},
+			required: []string{"postgis"},
+			builtin:  []string{"plpgsql"},
+		},
+		{
+			name:     "requires not declared",
+			metadata: &extensionMetadata{},
+			builtin:  []string{"plpgsql"},
+			want:     1,
+		},
+		{
+			name:     "requires not shipped with the base image",
+			metadata: &extensionMetadata{},
+			required: []string{"postgis"},
+			want:     1,
+		},
+		{
+			name:     "required extension not in requires",
+			metadata: &extensionMetadata{},
+			required: []string{"postgis", "vector"},
+			builtin:  []string{"plpgsql"},
+			want:     1,
+		},
+		{
+			name:     "trusted mismatch",
+			metadata: &extensionMetadata{Trusted: &trusted},
+			required: []string{"postgis"},
+			builtin:  []string{"plpgsql"},
+			want:     1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			verification := &imageVerification{}
+			verifyExtensionControl(verification, control, tt.metadata, tt.required,
+				[]string{"pgrouting", "pgrouting_extra"}, tt.builtin)
+			if len(verification.Problems) != tt.want {
+				t.Errorf("got problems %v, want %d", verification.Problems, tt.want)
+			}
+			// The properties not declared in the metadata are reported
+			if tt.metadata.Trusted == nil && len(verification.Notes) != 3 {
+				t.Errorf("got notes %v, want the trusted, superuser and relocatable values", verification.Notes)
+			}
+		})
+	}
+}
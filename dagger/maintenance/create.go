@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/language"
+)
+
+// scaffoldedExtension holds the values the templates of a new extension are
+// rendered with.
+type scaffoldedExtension struct {
+	Name           string
+	Versions       []string
+	Distros        []string
+	Package        string
+	DefaultVersion int
+	DefaultDistro  string
+}
+
+// renderExtensionTemplate renders a template file of a new extension. The
+// metadata.hcl file is returned in its canonical form, as checked by Fmt.
+func renderExtensionTemplate(fileName string, content string, extension scaffoldedExtension) (string, error) {
+	rendered, err := renderTemplate(fileName, content, extension)
+	if err != nil {
+		return "", err
+	}
+	if fileName != metadataFile {
+		return rendered, nil
+	}
+
+	formatted, err := formatMetadata([]byte(rendered))
+	if err != nil {
+		return "", fmt.Errorf("failed to format %s: %w", fileName, err)
+	}
+
+	return string(formatted), nil
+}
+
+// renderTemplate renders a template file with the values of a new extension.
+func renderTemplate(fileName string, content string, extension scaffoldedExtension) (string, error) {
+	toTitle := func(s string) string {
+		return cases.Title(language.English).String(s)
+	}
+
+	funcMap := template.FuncMap{
+		"replaceAll": strings.ReplaceAll,
+		"toTitle":    toTitle,
+	}
+
+	tmpl, err := template.New(fileName).Funcs(funcMap).Parse(content)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse template %s.tmpl: %w", fileName, err)
+	}
+	buf := &bytes.Buffer{}
+	if err := tmpl.Execute(buf, extension); err != nil {
+		return "", fmt.Errorf("failed to execute template %s.tmpl: %w", fileName, err)
+	}
+
+	return buf.String(), nil
+}
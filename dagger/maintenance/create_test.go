@@ -0,0 +1,43 @@
+package main
+
+import (
+	"os"
+	"testing"
+)
+
+func TestRenderMetadataTemplateIsCanonical(t *testing.T) {
+	content, err := os.ReadFile("../../templates/metadata.hcl.tmpl")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	extension := scaffoldedExtension{
+		Name:     "myextension",
+		Versions: []string{"17", "18"},
+		Distros:  []string{"bookworm", "trixie"},
+		Package:  "postgresql-%version%-myextension",
+	}
+
+	// The raw rendering of the template, with the default distributions,
+	// must already be canonical
+	rendered, err := renderTemplate(metadataFile, string(content), extension)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	formatted, err := formatMetadata([]byte(rendered))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(formatted) != rendered {
+		t.Errorf("got a non canonical template rendering:\n%s\nwant:\n%s", rendered, formatted)
+	}
+
+	// Distributions given in any order are sorted
+	extension.Distros = []string{"trixie", "bookworm"}
+	got, err := renderExtensionTemplate(metadataFile, string(content), extension)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != rendered {
+		t.Errorf("got:\n%s\nwant:\n%s", got, rendered)
+	}
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+)
+
+// debianVersion is a Debian package version: [epoch:]upstream_version[-debian_revision]
+type debianVersion struct {
+	Epoch    int
+	Upstream string
+	Revision string
+}
+
+// parseDebianVersion splits a Debian package version into its components.
+func parseDebianVersion(version string) debianVersion {
+	var parsed debianVersion
+
+	version = strings.TrimSpace(version)
+	if epoch, rest, ok := strings.Cut(version, ":"); ok {
+		if value, err := strconv.Atoi(epoch); err == nil {
+			parsed.Epoch = value
+			version = rest
+		}
+	}
+
+	if dash := strings.LastIndexByte(version, '-'); dash >= 0 {
+		parsed.Upstream, parsed.Revision = version[:dash], version[dash+1:]
+	} else {
+		parsed.Upstream = version
+	}
+
+	return parsed
+}
+
+// compareDebianVersions compares two Debian package versions following the
+// dpkg ordering, returning -1, 0 or +1.
+func compareDebianVersions(a, b string) int {
+	va, vb := parseDebianVersion(a), parseDebianVersion(b)
+	switch {
+	case va.Epoch < vb.Epoch:
+		return -1
+	case va.Epoch > vb.Epoch:
+		return 1
+	}
+
+	if c := compareDebianVersionPart(va.Upstream, vb.Upstream); c != 0 {
+		return c
+	}
+
+	return compareDebianVersionPart(va.Revision, vb.Revision)
+}
+
+// compareDebianVersionPart compares an upstream version or a revision,
+// alternating non-digit and digit sequences as dpkg does.
+func compareDebianVersionPart(a, b string) int {
+	for a != "" || b != "" {
+		var nonDigitA, nonDigitB string
+		nonDigitA, a = splitLeading(a, false)
+		nonDigitB, b = splitLeading(b, false)
+		if c := compareNonDigits(nonDigitA, nonDigitB); c != 0 {
+			return c
+		}
+
+		var digitA, digitB string
+		digitA, a = splitLeading(a, true)
+		digitB, b = splitLeading(b, true)
+		if c := compareDigits(digitA, digitB); c != 0 {
+			return c
+		}
+	}
+
+	return 0
+}
+
+// splitLeading splits a string after its leading digits, or non-digits.
+func splitLeading(s string, digits bool) (string, string) {
+	end := strings.IndexFunc(s, func(r rune) bool {
+		return (r >= '0' && r <= '9') != digits
+	})
+	if end < 0 {
+		return s, ""
+	}
+
+	return s[:end], s[end:]
+}
+
+// compareNonDigits compares non-digit sequences: letters sort before
+// non-letters and "~" sorts before anything, even the end of the string.
+func compareNonDigits(a, b string) int {
+	for i := 0; i < len(a) || i < len(b); i++ {
+		var ca, cb byte
+		if i < len(a) {
+			ca = a[i]
+		}
+		if i < len(b) {
+			cb = b[i]
+		}
+		if oa, ob := debianCharOrder(ca), debianCharOrder(cb); oa != ob {
+			if oa < ob {
+				return -1
+			}
+			return 1
+		}
+	}
+
+	return 0
+}
+
+// debianCharOrder returns the weight of a character in the dpkg ordering,
+// where 0 stands for the end of the string.
+func debianCharOrder(c byte) int {
+	switch {
+	case c == 0:
+		return 0
+	case c == '~':
+		return -1
+	case (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z'):
+		return int(c)
+	default:
+		return int(c) + 256
+	}
+}
+
+// compareDigits compares digit sequences numerically, an empty one being zero.
+func compareDigits(a, b string) int {
+	a = strings.TrimLeft(a, "0")
+	b = strings.TrimLeft(b, "0")
+	switch {
+	case len(a) != len(b):
+		if len(a) < len(b) {
+			return -1
+		}
+		return 1
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+
+	return 0
+}
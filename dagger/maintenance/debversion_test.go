@@ -0,0 +1,33 @@
+package main
+
+import "testing"
+
+func TestCompareDebianVersions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{a: "1.0", b: "1.0", want: 0},
+		{a: "1.0", b: "1.1", want: -1},
+		{a: "1.10", b: "1.9", want: 1},
+		{a: "1.0-1", b: "1.0-2", want: -1},
+		{a: "1:1.0", b: "2.0", want: 1},
+		{a: "1.0~rc1", b: "1.0", want: -1},
+		{a: "1.0", b: "1.0+dfsg", want: -1},
+		{a: "1.0a", b: "1.0+", want: -1},
+		{a: "3.13.1-1+b1", b: "3.13.1-1", want: 1},
+		{a: "0.8.1-2.pgdg13+1", b: "0.8.1-2.pgdg12+1", want: 1},
+		{a: "1:14.2.3+dfsg-1+b1", b: "1:14.2.3+dfsg-1", want: 1},
+		{a: "2.7.1-2", b: "2.7.1-2+deb13u1", want: -1},
+		{a: "01.0", b: "1.0", want: 0},
+	}
+
+	for _, tt := range tests {
+		if got := compareDebianVersions(tt.a, tt.b); got != tt.want {
+			t.Errorf("compareDebianVersions(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+		if got := compareDebianVersions(tt.b, tt.a); got != -tt.want {
+			t.Errorf("compareDebianVersions(%q, %q) = %d, want %d", tt.b, tt.a, got, -tt.want)
+		}
+	}
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// imageDiff holds the differences between two extension images.
+type imageDiff struct {
+	From        string             `json:"from"`
+	To          string             `json:"to"`
+	Annotations []annotationChange `json:"annotations,omitempty"`
+	Platforms   []platformDiff     `json:"platforms"`
+}
+
+// annotationChange is an annotation added, removed or modified between two images.
+type annotationChange struct {
+	Key  string `json:"key"`
+	From string `json:"from,omitempty"`
+	To   string `json:"to,omitempty"`
+}
+
+// platformDiff holds the file differences between two images of the same platform.
+type platformDiff struct {
+	Platform string `json:"platform"`
+	// Missing is set to the image lacking the platform, if any
+	Missing string        `json:"missing,omitempty"`
+	Added   []fileSummary `json:"added,omitempty"`
+	Removed []fileSummary `json:"removed,omitempty"`
+	Changed []fileChange  `json:"changed,omitempty"`
+}
+
+// fileChange is a file whose size or link target changed between two images.
+type fileChange struct {
+	Path         string `json:"path"`
+	FromSize     int64  `json:"fromSize"`
+	ToSize       int64  `json:"toSize"`
+	FromLinkname string `json:"fromLinkname,omitempty"`
+	ToLinkname   string `json:"toLinkname,omitempty"`
+}
+
+// isEmpty reports whether no file differences were found.
+func (d *platformDiff) isEmpty() bool {
+	return d.Missing == "" && len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// diffAnnotations returns the annotations which differ between two images, sorted by key.
+func diffAnnotations(from, to map[string]string) []annotationChange {
+	keys := slices.Sorted(maps.Keys(from))
+	for key := range to {
+		if _, ok := from[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	slices.Sort(keys)
+
+	var changes []annotationChange
+	for _, key := range keys {
+		if from[key] != to[key] {
+			changes = append(changes, annotationChange{Key: key, From: from[key], To: to[key]})
+		}
+	}
+
+	return changes
+}
+
+// diffImageFS compares the regular files and symbolic links of two image file systems.
+func diffImageFS(platform string, from, to *imageFS) platformDiff {
+	diff := platformDiff{Platform: platform}
+
+	for _, name := range slices.Sorted(maps.Keys(from.files)) {
+		fromFile := from.files[name]
+		if fromFile.Mode.IsDir() {
+			continue
+		}
+
+		toFile := to.file(name)
+		switch {
+		case toFile == nil || toFile.Mode.IsDir():
+			diff.Removed = append(diff.Removed, fileSummary{
+				Path:     name,
+				Size:     fromFile.Size,
+				Linkname: fromFile.Linkname,
+			})
+		case fromFile.Size != toFile.Size || fromFile.Linkname != toFile.Linkname:
+			diff.Changed = append(diff.Changed, fileChange{
+				Path:         name,
+				FromSize:     fromFile.Size,
+				ToSize:       toFile.Size,
+				FromLinkname: fromFile.Linkname,
+				ToLinkname:   toFile.Linkname,
+			})
+		}
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(to.files)) {
+		toFile := to.files[name]
+		if toFile.Mode.IsDir() {
+			continue
+		}
+		if fromFile := from.file(name); fromFile == nil || fromFile.Mode.IsDir() {
+			diff.Added = append(diff.Added, fileSummary{
+				Path:     name,
+				Size:     toFile.Size,
+				Linkname: toFile.Linkname,
+			})
+		}
+	}
+
+	return diff
+}
+
+// renderImageDiff renders the differences between two images in the given
+// format, either "markdown" or "json".
+func renderImageDiff(diff *imageDiff, format string) (string, error) {
+	switch format {
+	case "json":
+		data, err := json.MarshalIndent(diff, "", "  ")
+		if err != nil {
+			return "", err
+		}
+		return string(data) + "\n", nil
+	case "markdown":
+		return formatImageDiff(diff), nil
+	}
+
+	return "", fmt.Errorf("unsupported format %q, must be either markdown or json", format)
+}
+
+// formatImageDiff renders the differences between two images in Markdown,
+// suitable for release notes.
+func formatImageDiff(diff *imageDiff) string {
+	var out strings.Builder
+	fmt.Fprintf(&out, "## Changes from `%s` to `%s`\n", diff.From, diff.To)
+
+	if len(diff.Annotations) > 0 {
+		fmt.Fprintf(&out, "\n### Annotations\n\n")
+		fmt.Fprintf(&out, "| Annotation | From | To |\n")
+		fmt.Fprintf(&out, "|---|---|---|\n")
+		for _, change := range diff.Annotations {
+			fmt.Fprintf(&out, "| `%s` | %s | %s |\n", change.Key, change.From, change.To)
+		}
+	}
+
+	for _, platform := range diff.Platforms {
+		fmt.Fprintf(&out, "\n### %s\n\n", platform.Platform)
+		switch {
+		case platform.Missing != "":
+			fmt.Fprintf(&out, "Platform not available in `%s`.\n", platform.Missing)
+			continue
+		case platform.isEmpty():
+			fmt.Fprintf(&out, "No file changes.\n")
+			continue
+		}
+
+		writeFileList(&out, "Added", platform.Added)
+		writeFileList(&out, "Removed", platform.Removed)
+		if len(platform.Changed) > 0 {
+			fmt.Fprintf(&out, "**Changed**\n\n")
+			for _, change := range platform.Changed {
+				if change.FromLinkname != change.ToLinkname {
+					fmt.Fprintf(&out, "- `%s`: -> `%s` ⇒ -> `%s`\n", change.Path, change.FromLinkname, change.ToLinkname)
+					continue
+				}
+				fmt.Fprintf(&out, "- `%s`: %s ⇒ %s\n", change.Path, formatSize(change.FromSize), formatSize(change.ToSize))
+			}
+			fmt.Fprintln(&out)
+		}
+	}
+
+	return out.String()
+}
+
+// writeFileList renders a titled Markdown list of files.
+func writeFileList(out *strings.Builder, title string, files []fileSummary) {
+	if len(files) == 0 {
+		return
+	}
+
+	fmt.Fprintf(out, "**%s**\n\n", title)
+	for _, file := range files {
+		if file.Linkname != "" {
+			fmt.Fprintf(out, "- `%s` -> `%s`\n", file.Path, file.Linkname)
+			continue
+		}
+		fmt.Fprintf(out, "- `%s` (%s)\n", file.Path, formatSize(file.Size))
+	}
+	fmt.Fprintln(out)
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"io/fs"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestDiffAnnotations(t *testing.T) {
+	changes := diffAnnotations(
+		map[string]string{"version": "0.8.5", "sql": "0.8.5", "removed": "x", "same": "y"},
+		map[string]string{"version": "0.8.6", "sql": "0.8.6", "added": "z", "same": "y"},
+	)
+
+	want := []annotationChange{
+		{Key: "added", To: "z"},
+		{Key: "removed", From: "x"},
+		{Key: "sql", From: "0.8.5", To: "0.8.6"},
+		{Key: "version", From: "0.8.5", To: "0.8.6"},
+	}
+	if !slices.Equal(changes, want) {
+		t.Errorf("got %v, want %v", changes, want)
+	}
+}
+
+func TestDiffImageFS(t *testing.T) {
+	from := newTestImageFS(t, map[string]string{
+		"lib/vector.so":                         "old",
+		"share/extension/vector--0.8.5.sql":     "",
+		"share/extension/vector.control":        "default_version = '0.8.5'",
+		"system/libdropped.so.1":                "",
+		"licenses/postgresql-18-pgvector/notes": "",
+	})
+	from.add(&imageFile{Name: "system/libfoo.so.1", Mode: fs.ModeSymlink, Linkname: "libfoo.so.1.0"})
+
+	to := newTestImageFS(t, map[string]string{
+		"lib/vector.so":                            "newer",
+		"share/extension/vector--0.8.6.sql":        "",
+		"share/extension/vector--0.8.5--0.8.6.sql": "",
+		"share/extension/vector.control":           "default_version = '0.8.6'",
+		"licenses/postgresql-18-pgvector/notes":    "",
+	})
+	to.add(&imageFile{Name: "system/libfoo.so.1", Mode: fs.ModeSymlink, Linkname: "libfoo.so.1.1"})
+
+	diff := diffImageFS("linux/amd64", from, to)
+
+	var added, removed, changed []string
+	for _, file := range diff.Added {
+		added = append(added, file.Path)
+	}
+	for _, file := range diff.Removed {
+		removed = append(removed, file.Path)
+	}
+	for _, change := range diff.Changed {
+		changed = append(changed, change.Path)
+	}
+
+	if want := []string{
+		"share/extension/vector--0.8.5--0.8.6.sql",
+		"share/extension/vector--0.8.6.sql",
+	}; !slices.Equal(added, want) {
+		t.Errorf("added: got %v, want %v", added, want)
+	}
+	if want := []string{
+		"share/extension/vector--0.8.5.sql",
+		"system/libdropped.so.1",
+	}; !slices.Equal(removed, want) {
+		t.Errorf("removed: got %v, want %v", removed, want)
+	}
+	// The control file keeps the same size, so it's not reported as changed
+	if want := []string{"lib/vector.so", "system/libfoo.so.1"}; !slices.Equal(changed, want) {
+		t.Errorf("changed: got %v, want %v", changed, want)
+	}
+
+	markdown, err := renderImageDiff(&imageDiff{
+		From:      "pgvector:0.8.5-18-trixie",
+		To:        "pgvector:0.8.6-18-trixie",
+		Platforms: []platformDiff{diff, {Platform: "linux/arm64", Missing: "pgvector:0.8.5-18-trixie"}},
+	}, "markdown")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, want := range []string{
+		"## Changes from `pgvector:0.8.5-18-trixie` to `pgvector:0.8.6-18-trixie`",
+		"- `share/extension/vector--0.8.5--0.8.6.sql` (0 B)",
+		"- `system/libdropped.so.1` (0 B)",
+		"- `lib/vector.so`: 3 B ⇒ 5 B",
+		"- `system/libfoo.so.1`: -> `libfoo.so.1.0` ⇒ -> `libfoo.so.1.1`",
+		"Platform not available in `pgvector:0.8.5-18-trixie`.",
+	} {
+		if !strings.Contains(markdown, want) {
+			t.Errorf("markdown doesn't contain %q:\n%s", want, markdown)
+		}
+	}
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"bytes"
+	"debug/elf"
+	"io/fs"
+	"maps"
+	"path"
+	"slices"
+	"strings"
+)
+
+// maxSymlinkHops is the maximum number of symbolic links followed
+// while resolving a library.
+const maxSymlinkHops = 16
+
+// platformMachines maps the platforms we build for to the expected ELF machine type.
+var platformMachines = map[string]elf.Machine{
+	"linux/amd64": elf.EM_X86_64,
+	"linux/arm64": elf.EM_AARCH64,
+}
+
+// platformTriplets maps the platforms we build for to their Debian multiarch tuple.
+var platformTriplets = map[string]string{
+	"linux/amd64": "x86_64-linux-gnu",
+	"linux/arm64": "aarch64-linux-gnu",
+}
+
+// elfObject holds the properties of an ELF object relevant to the image analysis.
+type elfObject struct {
+	Machine elf.Machine
+	Soname  string
+	Needed  []string
+}
+
+// isELF reports whether the content of a file starts with the ELF magic number.
+func isELF(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(elf.ELFMAG))
+}
+
+// mayContainELF reports whether a file of an extension image may hold an ELF
+// object, to avoid loading the content of SQL scripts, licenses and bitcode.
+func mayContainELF(name string) bool {
+	top, _, _ := strings.Cut(cleanImagePath(name), "/")
+	return top != "share" && top != licensesDir && path.Ext(name) != ".bc"
+}
+
+// isSharedObject reports whether the file name refers to a shared object,
+// either unversioned (libfoo.so) or versioned (libfoo.so.1.2).
+func isSharedObject(name string) bool {
+	base := path.Base(name)
+	return strings.HasSuffix(base, ".so") || strings.Contains(base, ".so.")
+}
+
+// parseELF parses an ELF object, returning its machine, DT_SONAME and DT_NEEDED entries.
+func parseELF(data []byte) (*elfObject, error) {
+	file, err := elf.NewFile(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	needed, err := file.ImportedLibraries()
+	if err != nil {
+		return nil, err
+	}
+
+	var soname string
+	if sonames, err := file.DynString(elf.DT_SONAME); err == nil && len(sonames) > 0 {
+		soname = sonames[0]
+	}
+
+	return &elfObject{
+		Machine: file.Machine,
+		Soname:  soname,
+		Needed:  needed,
+	}, nil
+}
+
+// resolveLink follows the symbolic links of an image entry, returning
+// the entry they point to or nil if the chain is dangling.
+func resolveLink(fsys *imageFS, file *imageFile) *imageFile {
+	for range maxSymlinkHops {
+		if file == nil || file.Mode&fs.ModeSymlink == 0 {
+			return file
+		}
+		target := file.Linkname
+		if !path.IsAbs(target) {
+			target = path.Join(path.Dir(file.Name), target)
+		}
+		file = fsys.file(target)
+	}
+
+	return nil
+}
+
+// systemLibraryDirectories returns the directories of a Debian based image
+// searched by the dynamic linker, for a given platform.
+func systemLibraryDirectories(platform string) []string {
+	dirs := []string{"lib", "lib64", "usr/lib", "usr/lib64", "usr/local/lib"}
+	if triplet, ok := platformTriplets[platform]; ok {
+		dirs = append(dirs, path.Join("lib", triplet), path.Join("usr/lib", triplet))
+	}
+
+	return dirs
+}
+
+// baseImageLibraries returns the set of shared object names available in the
+// base image, for the given platform, in the dynamic linker search path.
+func baseImageLibraries(fsys *imageFS, platform string) map[string]bool {
+	libraries := make(map[string]bool)
+	for _, dir := range systemLibraryDirectories(platform) {
+		for _, name := range fsys.glob(path.Join(dir, "*.so*")) {
+			if resolveLink(fsys, fsys.file(name)) != nil {
+				libraries[path.Base(name)] = true
+			}
+		}
+	}
+
+	return libraries
+}
+
+// extensionLibraryDirectories returns the directories of an extension image
+// which hold shared objects: the dynamic library path and the ld library path.
+func extensionLibraryDirectories(metadata *extensionMetadata) []string {
+	dirs := libraryDirectories(metadata)
+	for _, dir := range metadata.LdLibraryPath {
+		dir = cleanImagePath(dir)
+		if !slices.Contains(dirs, dir) {
+			dirs = append(dirs, dir)
+		}
+	}
+
+	return dirs
+}
+
+// verifyELFDependencies parses every shared object shipped by an extension
+// image and checks that it targets the image platform and that each of its
+// DT_NEEDED entries resolves against the libraries of the image itself or
+// the ones of its base image.
+func verifyELFDependencies(
+	verification *imageVerification,
+	fsys *imageFS,
+	metadata *extensionMetadata,
+	baseLibraries map[string]bool,
+) {
+	dirs := extensionLibraryDirectories(metadata)
+	expectedMachine, knownPlatform := platformMachines[verification.Platform]
+
+	for _, dir := range dirs {
+		for _, name := range fsys.glob(path.Join(dir, "*")) {
+			file := fsys.file(name)
+			// Skip symbolic and hard links, their target is analysed on its own
+			if !file.Mode.IsRegular() || file.Linkname != "" || !isSharedObject(name) {
+				continue
+			}
+
+			object, err := parseELF(file.Data)
+			if err != nil {
+				verification.addProblem("%s: cannot parse ELF object: %v", name, err)
+				continue
+			}
+
+			if knownPlatform && object.Machine != expectedMachine {
+				verification.addProblem("%s: ELF machine %s doesn't match platform %s (expected %s)",
+					name, object.Machine, verification.Platform, expectedMachine)
+			}
+
+			for _, needed := range object.Needed {
+				if baseLibraries[needed] || resolvesInImage(fsys, dirs, needed) {
+					continue
+				}
+				verification.addProblem("%s: unresolved dependency %s", name, needed)
+			}
+		}
+	}
+}
+
+// verifyELFMachines checks that every ELF object shipped by an extension
+// image, including executables, targets the image platform. The shared
+// objects of the library directories are checked by verifyELFDependencies.
+func verifyELFMachines(verification *imageVerification, fsys *imageFS, metadata *extensionMetadata) {
+	expectedMachine, knownPlatform := platformMachines[verification.Platform]
+	if !knownPlatform {
+		return
+	}
+	dirs := extensionLibraryDirectories(metadata)
+
+	for _, name := range slices.Sorted(maps.Keys(fsys.files)) {
+		file := fsys.files[name]
+		if !file.Mode.IsRegular() || file.Linkname != "" || !isELF(file.Data) {
+			continue
+		}
+		if isSharedObject(file.Name) && slices.Contains(dirs, path.Dir(file.Name)) {
+			continue
+		}
+
+		object, err := parseELF(file.Data)
+		if err != nil {
+			verification.addProblem("%s: cannot parse ELF object: %v", file.Name, err)
+			continue
+		}
+		if object.Machine != expectedMachine {
+			verification.addProblem("%s: ELF machine %s doesn't match platform %s (expected %s)",
+				file.Name, object.Machine, verification.Platform, expectedMachine)
+		}
+	}
+}
+
+// resolvesInImage reports whether a library can be found, without dangling
+// symbolic links, in one of the given directories of the image.
+func resolvesInImage(fsys *imageFS, dirs []string, library string) bool {
+	return slices.ContainsFunc(dirs, func(dir string) bool {
+		return resolveLink(fsys, fsys.file(path.Join(dir, library))) != nil
+	})
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"bytes"
+	"debug/elf"
+	"encoding/binary"
+	"io/fs"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// buildTestELF builds a minimal 64-bit little-endian ELF shared object for the
+// given machine, whose dynamic section lists the given DT_NEEDED entries.
+func buildTestELF(t *testing.T, machine elf.Machine, needed ...string) []byte {
+	t.Helper()
+
+	dynstr := []byte{0}
+	var dynamic []elf.Dyn64
+	for _, library := range needed {
+		dynamic = append(dynamic, elf.Dyn64{Tag: int64(elf.DT_NEEDED), Val: uint64(len(dynstr))})
+		dynstr = append(append(dynstr, library...), 0)
+	}
+	dynamic = append(dynamic, elf.Dyn64{Tag: int64(elf.DT_NULL)})
+
+	var dynamicData bytes.Buffer
+	if err := binary.Write(&dynamicData, binary.LittleEndian, dynamic); err != nil {
+		t.Fatal(err)
+	}
+	shstrtab := []byte("\x00.dynstr\x00.dynamic\x00.shstrtab\x00")
+
+	const headerSize = 64
+	dynstrOffset := uint64(headerSize)
+	dynamicOffset := dynstrOffset + uint64(len(dynstr))
+	shstrtabOffset := dynamicOffset + uint64(dynamicData.Len())
+	sectionsOffset := shstrtabOffset + uint64(len(shstrtab))
+
+	header := elf.Header64{
+		Type:      uint16(elf.ET_DYN),
+		Machine:   uint16(machine),
+		Version:   uint32(elf.EV_CURRENT),
+		Shoff:     sectionsOffset,
+		Ehsize:    headerSize,
+		Shentsize: 64,
+		Shnum:     4,
+		Shstrndx:  3,
+	}
+	copy(header.Ident[:], elf.ELFMAG)
+	header.Ident[elf.EI_CLASS] = byte(elf.ELFCLASS64)
+	header.Ident[elf.EI_DATA] = byte(elf.ELFDATA2LSB)
+	header.Ident[elf.EI_VERSION] = byte(elf.EV_CURRENT)
+
+	sections := []elf.Section64{
+		{},
+		{Name: 1, Type: uint32(elf.SHT_STRTAB), Off: dynstrOffset, Size: uint64(len(dynstr)), Addralign: 1},
+		{Name: 9, Type: uint32(elf.SHT_DYNAMIC), Off: dynamicOffset, Size: uint64(dynamicData.Len()),
+			Link: 1, Addralign: 8, Entsize: 16},
+		{Name: 18, Type: uint32(elf.SHT_STRTAB), Off: shstrtabOffset, Size: uint64(len(shstrtab)), Addralign: 1},
+	}
+
+	var out bytes.Buffer
+	for _, data := range []any{header, dynstr, dynamicData.Bytes(), shstrtab, sections} {
+		if err := binary.Write(&out, binary.LittleEndian, data); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return out.Bytes()
+}
+
+func TestParseELF(t *testing.T) {
+	object, err := parseELF(buildTestELF(t, elf.EM_AARCH64, "libgeos_c.so.1", "libc.so.6"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if object.Machine != elf.EM_AARCH64 {
+		t.Errorf("machine: got %s, want %s", object.Machine, elf.EM_AARCH64)
+	}
+	if !slices.Equal(object.Needed, []string{"libgeos_c.so.1", "libc.so.6"}) {
+		t.Errorf("needed: got %v", object.Needed)
+	}
+
+	if _, err := parseELF([]byte("INPUT(-lfoo)")); err == nil {
+		t.Error("expected an error parsing a linker script")
+	}
+}
+
+func TestIsSharedObject(t *testing.T) {
+	cases := map[string]bool{
+		"lib/vector.so":                  true,
+		"system/libgeos_c.so.1":          true,
+		"system/libgeos_c.so.1.19.2":     true,
+		"lib/bitcode/vector.index.bc":    false,
+		"share/extension/vector.control": false,
+		"lib/libsomething.sol":           false,
+	}
+	for name, want := range cases {
+		if got := isSharedObject(name); got != want {
+			t.Errorf("isSharedObject(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestVerifyELFDependencies(t *testing.T) {
+	metadata := &extensionMetadata{
+		Name:          "postgis",
+		SQLName:       "postgis",
+		LdLibraryPath: []string{"system"},
+	}
+	baseLibraries := map[string]bool{"libc.so.6": true, "libstdc++.so.6": true}
+
+	tests := []struct {
+		name         string
+		platform     string
+		files        map[string]string
+		symlinks     map[string]string
+		wantProblems []string
+	}{
+		{
+			name:     "dependencies resolved by the image and the base image",
+			platform: "linux/amd64",
+			files: map[string]string{
+				"lib/postgis-3.so":         string(buildTestELF(t, elf.EM_X86_64, "libgeos_c.so.1", "libc.so.6")),
+				"system/libgeos_c.so.1.19": string(buildTestELF(t, elf.EM_X86_64, "libstdc++.so.6")),
+			},
+			symlinks: map[string]string{
+				"system/libgeos_c.so.1": "libgeos_c.so.1.19",
+			},
+		},
+		{
+			name:     "missing system library",
+			platform: "linux/amd64",
+			files: map[string]string{
+				"lib/postgis-3.so": string(buildTestELF(t, elf.EM_X86_64, "libgeos_c.so.1", "libc.so.6")),
+			},
+			wantProblems: []string{"lib/postgis-3.so: unresolved dependency libgeos_c.so.1"},
+		},
+		{
+			name:     "dangling symbolic link",
+			platform: "linux/amd64",
+			files: map[string]string{
+				"lib/postgis-3.so": string(buildTestELF(t, elf.EM_X86_64, "libgeos_c.so.1")),
+			},
+			symlinks: map[string]string{
+				"system/libgeos_c.so.1": "libgeos_c.so.1.19",
+			},
+			wantProblems: []string{"lib/postgis-3.so: unresolved dependency libgeos_c.so.1"},
+		},
+		{
+			name:     "wrong architecture",
+			platform: "linux/arm64",
+			files: map[string]string{
+				"lib/postgis-3.so": string(buildTestELF(t, elf.EM_X86_64, "libc.so.6")),
+			},
+			wantProblems: []string{"lib/postgis-3.so: ELF machine EM_X86_64 doesn't match platform linux/arm64"},
+		},
+		{
+			name:     "not an ELF object",
+			platform: "linux/amd64",
+			files: map[string]string{
+				"lib/postgis-3.so": "garbage",
+			},
+			wantProblems: []string{"lib/postgis-3.so: cannot parse ELF object"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fsys := newTestImageFS(t, tt.files)
+			for name, target := range tt.symlinks {
+				fsys.add(&imageFile{Name: name, Mode: fs.ModeSymlink | 0o777, Linkname: target})
+			}
+
+			verification := &imageVerification{Platform: tt.platform}
+			verifyELFDependencies(verification, fsys, metadata, baseLibraries)
+
+			if len(verification.Problems) != len(tt.wantProblems) {
+				t.Fatalf("got problems %q, want %d problem(s)", verification.Problems, len(tt.wantProblems))
+			}
+			for i, want := range tt.wantProblems {
+				if !strings.Contains(verification.Problems[i], want) {
+					t.Errorf("problem %d: got %q, want it to contain %q", i, verification.Problems[i], want)
+				}
+			}
+		})
+	}
+}
+
+func TestBaseImageLibraries(t *testing.T) {
+	fsys := newTestImageFS(t, map[string]string{
+		"usr/lib/x86_64-linux-gnu/libc.so.6":        "",
+		"usr/lib/x86_64-linux-gnu/libzstd.so.1.5.6": "",
+		"usr/lib/x86_64-linux-gnu/perl/libperl.so":  "",
+		"usr/share/doc/libc6/copyright":             "",
+	})
+	fsys.add(&imageFile{
+		Name:     "usr/lib/x86_64-linux-gnu/libzstd.so.1",
+		Mode:     fs.ModeSymlink | 0o777,
+		Linkname: "libzstd.so.1.5.6",
+	})
+
+	libraries := baseImageLibraries(fsys, "linux/amd64")
+	for _, name := range []string{"libc.so.6", "libzstd.so.1", "libzstd.so.1.5.6"} {
+		if !libraries[name] {
+			t.Errorf("expected %s to be available", name)
+		}
+	}
+	if libraries["libperl.so"] {
+		t.Error("libperl.so is outside the linker search path")
+	}
+}
+
+func TestVerifyELFMachines(t *testing.T) {
+	metadata := &extensionMetadata{
+		Name:    "postgis",
+		SQLName: "postgis",
+		BinPath: []string{"bin"},
+	}
+	fsys := newTestImageFS(t, map[string]string{
+		// Shared objects of the library directories are checked by verifyELFDependencies
+		"lib/postgis-3.so":          string(buildTestELF(t, elf.EM_X86_64)),
+		"bin/shp2pgsql":             string(buildTestELF(t, elf.EM_X86_64)),
+		"bin/raster2pgsql":          string(buildTestELF(t, elf.EM_AARCH64)),
+		"bin/postgis_restore":       "#!/usr/bin/perl",
+		"share/extension/x.control": "",
+	})
+
+	verification := &imageVerification{Platform: "linux/arm64"}
+	verifyELFMachines(verification, fsys, metadata)
+
+	want := []string{"bin/shp2pgsql: ELF machine EM_X86_64 doesn't match platform linux/arm64 (expected EM_AARCH64)"}
+	if !slices.Equal(verification.Problems, want) {
+		t.Errorf("got problems %v, want %v", verification.Problems, want)
+	}
+}
+
+func TestMayContainELF(t *testing.T) {
+	for name, want := range map[string]bool{
+		"lib/vector.so":                    true,
+		"bin/shp2pgsql":                    true,
+		"system/libgeos.so.3":              true,
+		"share/extension/vector.control":   false,
+		"licenses/postgis/copyright":       false,
+		"lib/bitcode/vector/src/vector.bc": false,
+		"/share/extension/vector--0.8.sql": false,
+	} {
+		if got := mayContainELF(name); got != want {
+			t.Errorf("mayContainELF(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
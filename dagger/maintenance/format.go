@@ -0,0 +1,267 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"fmt"
+	"slices"
+
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+)
+
+// metadataHeader is the SPDX header of the metadata.hcl files
+const metadataHeader = "# SPDX-FileCopyrightText: Copyright © contributors to CloudNativePG, " +
+	"established as CloudNativePG a Series of LF Projects, LLC.\n" +
+	"# SPDX-License-Identifier: Apache-2.0\n"
+
+// metadataAttributeOrder is the order of the metadata attributes, as in
+// templates/metadata.hcl.tmpl. Unknown attributes come after them, versions
+// always comes last.
+var metadataAttributeOrder = []string{
+	"name",
+	"sql_name",
+	"image_name",
+	"packages",
+	"licenses",
+	"shared_preload_libraries",
+	"postgresql_parameters",
+	"extension_control_path",
+	"dynamic_library_path",
+	"ld_library_path",
+	"bin_path",
+	"env",
+	"auto_update_os_libs",
+	"required_extensions",
+	"create_extension",
+	"trusted",
+	"superuser",
+	"relocatable",
+}
+
+// formatMetadata returns the canonical form of a metadata.hcl file: the SPDX
+// header, the metadata attributes in the order of the template, the versions
+// sorted by distribution and PG major, quoted package and sql versions, and
+// "=" signs aligned within the groups of attributes not separated by a blank
+// line. Comments stay attached to the attribute they precede.
+func formatMetadata(src []byte) ([]byte, error) {
+	document, err := parseMetadataDocument(src)
+	if err != nil {
+		return nil, err
+	}
+	if err := document.quoteVersions(); err != nil {
+		return nil, err
+	}
+	if err := document.sortVersions(); err != nil {
+		return nil, err
+	}
+	if err := document.sortMetadata(); err != nil {
+		return nil, err
+	}
+	if err := document.alignAttributes(); err != nil {
+		return nil, err
+	}
+
+	content, err := document.bytes()
+	if err != nil {
+		return nil, err
+	}
+
+	return withMetadataHeader(content), nil
+}
+
+// withMetadataHeader replaces the SPDX comments at the top of a file with the
+// canonical header.
+func withMetadataHeader(content []byte) []byte {
+	for bytes.HasPrefix(content, []byte("# SPDX-")) {
+		_, rest, _ := bytes.Cut(content, []byte("\n"))
+		content = rest
+	}
+
+	return append([]byte(metadataHeader), content...)
+}
+
+// quoteVersions turns the package and sql versions written as numbers, such
+// as sql = 1.10, into quoted strings.
+func (d *metadataDocument) quoteVersions() error {
+	entries, err := d.versionEntries()
+	if err != nil {
+		return err
+	}
+
+	// Going backwards keeps the indices of the entries still to process valid
+	for _, entry := range slices.Backward(entries) {
+		for _, name := range slices.Backward(renovateAttributes) {
+			attribute, ok := entry.Attributes[name]
+			if !ok || attribute.ValueEnd-attribute.ValueStart != 1 ||
+				d.tokens[attribute.ValueStart].Type != hclsyntax.TokenNumberLit {
+				continue
+			}
+			number := d.tokens[attribute.ValueStart]
+			d.tokens = slices.Replace(d.tokens, attribute.ValueStart, attribute.ValueEnd,
+				&hclwrite.Token{Type: hclsyntax.TokenOQuote, Bytes: []byte(`"`), SpacesBefore: number.SpacesBefore},
+				&hclwrite.Token{Type: hclsyntax.TokenQuotedLit, Bytes: number.Bytes},
+				&hclwrite.Token{Type: hclsyntax.TokenCQuote, Bytes: []byte(`"`)},
+			)
+		}
+	}
+
+	return nil
+}
+
+// sortVersions sorts the distributions of the versions object by name and
+// their PG majors by number.
+func (d *metadataDocument) sortVersions() error {
+	versions, err := d.versionsEntry()
+	if err != nil {
+		return err
+	}
+	noBlankLine := func(int, string, bool) bool { return false }
+	d.sortObject(versions, cmp.Compare[string], noBlankLine)
+
+	distributions, err := d.distributionEntries()
+	if err != nil {
+		return err
+	}
+	// Going backwards keeps the indices of the distributions still to sort valid
+	for _, distribution := range slices.Backward(distributions) {
+		if !distribution.isObject(d.tokens) {
+			return fmt.Errorf("distribution %s is not an object", distribution.Key)
+		}
+		d.sortObject(distribution, compareDigits, noBlankLine)
+	}
+
+	return nil
+}
+
+// sortMetadata sorts the attributes of the metadata object in the order of
+// the template, keeping a blank line before versions.
+func (d *metadataDocument) sortMetadata() error {
+	metadata, ok := findEntry(objectEntries(d.tokens, -1, len(d.tokens)), "metadata")
+	if !ok || !metadata.isObject(d.tokens) {
+		return fmt.Errorf("no metadata object found in %s", metadataFile)
+	}
+
+	rank := func(key string) int {
+		if key == "versions" {
+			return len(metadataAttributeOrder) + 1
+		}
+		if index := slices.Index(metadataAttributeOrder, key); index >= 0 {
+			return index
+		}
+		return len(metadataAttributeOrder)
+	}
+	d.sortObject(metadata, func(a, b string) int {
+		return cmp.Compare(rank(a), rank(b))
+	}, func(index int, key string, hadBlankLine bool) bool {
+		return index > 0 && (hadBlankLine || key == "versions")
+	})
+
+	return nil
+}
+
+// sortObject stably sorts the entries of a multi-line object by key, moving
+// the comments and blank lines preceding each entry along with it. The
+// blankLine function tells whether the entry at the given position must be
+// preceded by a blank line, given whether it was.
+func (d *metadataDocument) sortObject(
+	object tokenEntry,
+	compare func(a, b string) int,
+	blankLine func(index int, key string, hadBlankLine bool) bool,
+) {
+	open, close := object.ValueStart, object.ValueEnd-1
+	if d.tokens[open+1].Type != hclsyntax.TokenNewline {
+		return
+	}
+
+	type span struct {
+		key    string
+		tokens hclwrite.Tokens
+	}
+	var spans []span
+	start := open + 2
+	for _, entry := range objectEntries(d.tokens, open, close) {
+		end := lineEnd(d.tokens, entry.ValueEnd)
+		spans = append(spans, span{key: entry.Key, tokens: slices.Clone(d.tokens[start:end])})
+		start = end
+	}
+	slices.SortStableFunc(spans, func(a, b span) int {
+		return compare(a.key, b.key)
+	})
+
+	interior := hclwrite.Tokens{}
+	for i, span := range spans {
+		hadBlankLine := false
+		for len(span.tokens) > 0 && span.tokens[0].Type == hclsyntax.TokenNewline {
+			span.tokens = span.tokens[1:]
+			hadBlankLine = true
+		}
+		if blankLine(i, span.key, hadBlankLine) {
+			interior = append(interior, &hclwrite.Token{Type: hclsyntax.TokenNewline, Bytes: []byte("\n")})
+		}
+		interior = append(interior, span.tokens...)
+	}
+	d.tokens = slices.Replace(d.tokens, open+2, start, interior...)
+}
+
+// lineEnd returns the index after the comma, trailing comment and newline
+// ending the value which ends at the given index.
+func lineEnd(tokens hclwrite.Tokens, end int) int {
+	if end < len(tokens) && tokens[end].Type == hclsyntax.TokenComma {
+		end++
+	}
+	// Comments include the newline ending them
+	if end < len(tokens) && (tokens[end].Type == hclsyntax.TokenComment || tokens[end].Type == hclsyntax.TokenNewline) {
+		end++
+	}
+
+	return end
+}
+
+// alignAttributes aligns the "=" signs of the metadata attributes and of the
+// attributes of every version entry.
+func (d *metadataDocument) alignAttributes() error {
+	metadata, err := d.metadataEntries()
+	if err != nil {
+		return err
+	}
+	d.alignEntries(metadata)
+
+	entries, err := d.versionEntries()
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		d.alignEntries(objectEntriesOf(d.tokens, entry.Entry))
+	}
+
+	return nil
+}
+
+// alignEntries aligns the "=" signs of each group of consecutive entries,
+// groups being separated by blank lines. Comments don't split groups.
+func (d *metadataDocument) alignEntries(entries []tokenEntry) {
+	keyWidth := func(entry tokenEntry) int {
+		return len(d.tokens[entry.Start:entry.ValueStart-1].Bytes()) - d.tokens[entry.Start].SpacesBefore
+	}
+
+	for first := 0; first < len(entries); {
+		last := first + 1
+		for last < len(entries) && !slices.ContainsFunc(
+			d.tokens[lineEnd(d.tokens, entries[last-1].ValueEnd):entries[last].Start],
+			func(token *hclwrite.Token) bool { return token.Type == hclsyntax.TokenNewline },
+		) {
+			last++
+		}
+
+		width := 0
+		for _, entry := range entries[first:last] {
+			width = max(width, keyWidth(entry))
+		}
+		for _, entry := range entries[first:last] {
+			d.tokens[entry.ValueStart-1].SpacesBefore = width - keyWidth(entry) + 1
+			d.tokens[entry.ValueStart].SpacesBefore = 1
+		}
+		first = last
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"testing"
+)
+
+const unformattedMetadata = `metadata = {
+  sql_name = "vector"
+  # The extension name
+  name = "pgvector"
+  env                      = {
+    "FOO" = "bar",
+  }
+  versions = {
+    trixie = {
+      "18" = {
+        // renovate: suite=trixie-pgdg depName=postgresql-18-pgvector
+        package = "0.8.1-2.pgdg13+1"
+        // renovate: suite=trixie-pgdg depName=postgresql-18-pgvector extractVersion=^(?<version>\d+\.\d+)
+        sql = 0.8
+      }
+      "9" = {
+        // renovate: suite=trixie-pgdg depName=postgresql-9-pgvector
+        package = "0.5.0-1.pgdg13+1"
+      }
+    }
+    bookworm = {
+      "18" = {
+        package = "0.8.1-2.pgdg12+1"
+      }
+    }
+  }
+
+  packages = ["postgresql-%version%-pgvector"]
+  create_extension = true
+}
+
+target "default" {
+  args = {
+    FOO = "bar"
+  }
+}
+`
+
+const formattedMetadata = `# SPDX-FileCopyrightText: Copyright © contributors to CloudNativePG, established as CloudNativePG a Series of LF Projects, LLC.
+# SPDX-License-Identifier: Apache-2.0
+metadata = {
+  # The extension name
+  name     = "pgvector"
+  sql_name = "vector"
+
+  packages         = ["postgresql-%version%-pgvector"]
+  env              = {
+    "FOO" = "bar",
+  }
+  create_extension = true
+
+  versions = {
+    bookworm = {
+      "18" = {
+        package = "0.8.1-2.pgdg12+1"
+      }
+    }
+    trixie = {
+      "9" = {
+        // renovate: suite=trixie-pgdg depName=postgresql-9-pgvector
+        package = "0.5.0-1.pgdg13+1"
+      }
+      "18" = {
+        // renovate: suite=trixie-pgdg depName=postgresql-18-pgvector
+        package = "0.8.1-2.pgdg13+1"
+        // renovate: suite=trixie-pgdg depName=postgresql-18-pgvector extractVersion=^(?<version>\d+\.\d+)
+        sql     = "0.8"
+      }
+    }
+  }
+}
+
+target "default" {
+  args = {
+    FOO = "bar"
+  }
+}
+`
+
+func TestFormatMetadata(t *testing.T) {
+	got, err := formatMetadata([]byte(unformattedMetadata))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(got) != formattedMetadata {
+		t.Errorf("got:\n%s\nwant:\n%s", got, formattedMetadata)
+	}
+
+	again, err := formatMetadata(got)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(again) != formattedMetadata {
+		t.Errorf("got:\n%s\nwant the formatted file unchanged", again)
+	}
+}
+
+func TestWithMetadataHeader(t *testing.T) {
+	got := string(withMetadataHeader([]byte("# SPDX-License-Identifier: Apache-2.0\nmetadata = {}\n")))
+	if want := metadataHeader + "metadata = {}\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -43,20 +43,9 @@ func getImageAnnotations(ctx context.Context, imageRef string, username string,
 		return nil, err
 	}
 
-	var opts []remote.Option
-	if password != nil && username != "" {
-		plainPassword, err := password.Plaintext(ctx)
-		if err != nil {
-			return nil, fmt.Errorf("failed to read registry password: %w", err)
-		}
-
-		if plainPassword != "" {
-			auth := authn.FromConfig(authn.AuthConfig{
-				Username: username,
-				Password: plainPassword,
-			})
-			opts = append(opts, remote.WithAuth(auth))
-		}
+	opts, err := registryOptions(ctx, username, password)
+	if err != nil {
+		return nil, err
 	}
 
 	head, err := remote.Get(ref, opts...)
@@ -82,6 +71,246 @@ func getImageAnnotations(ctx context.Context, imageRef string, username string,
 	return nil, fmt.Errorf("unsupported media type: %s", head.MediaType)
 }
 
+// resolvedExtensionImage is an extension image matched against the metadata
+// of the extension, through the distribution and PostgreSQL major version
+// declared in its annotations.
+type resolvedExtensionImage struct {
+	Metadata     *extensionMetadata
+	Reference    string
+	Annotations  map[string]string
+	Distribution string
+	PgMajor      int
+	Version      extensionVersion
+}
+
+// resolveExtensionImage parses the metadata of the target extension and
+// matches it against the annotations of the given extension image.
+// If no image is given, the default extension image is used.
+func resolveExtensionImage(
+	ctx context.Context,
+	source *dagger.Directory,
+	target string,
+	extensionImage string,
+	username string,
+	password *dagger.Secret,
+) (*resolvedExtensionImage, error) {
+	metadata, err := parseExtensionMetadata(ctx, source.Directory(target))
+	if err != nil {
+		return nil, err
+	}
+
+	targetExtensionImage := extensionImage
+	if targetExtensionImage == "" {
+		targetExtensionImage, err = getDefaultExtensionImage(metadata)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	annotations, err := getImageAnnotations(ctx, targetExtensionImage, username, password)
+	if err != nil {
+		return nil, err
+	}
+
+	distribution, pgMajor, err := parseImageCoordinates(annotations)
+	if err != nil {
+		return nil, fmt.Errorf("extension image %s: %w", targetExtensionImage, err)
+	}
+
+	version, ok := metadata.Versions[distribution][strconv.Itoa(pgMajor)]
+	if !ok {
+		return nil, fmt.Errorf(
+			"extension image %s: no version declared in metadata for distribution %q and version %d",
+			targetExtensionImage, distribution, pgMajor)
+	}
+
+	return &resolvedExtensionImage{
+		Metadata:     metadata,
+		Reference:    targetExtensionImage,
+		Annotations:  annotations,
+		Distribution: distribution,
+		PgMajor:      pgMajor,
+		Version:      version,
+	}, nil
+}
+
+// registryOptions returns the options to access a remote registry.
+// If username and password are provided, they will be used for registry authentication.
+func registryOptions(ctx context.Context, username string, password *dagger.Secret) ([]remote.Option, error) {
+	opts := []remote.Option{remote.WithContext(ctx)}
+	if password == nil || username == "" {
+		return opts, nil
+	}
+
+	plainPassword, err := password.Plaintext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read registry password: %w", err)
+	}
+
+	if plainPassword != "" {
+		auth := authn.FromConfig(authn.AuthConfig{
+			Username: username,
+			Password: plainPassword,
+		})
+		opts = append(opts, remote.WithAuth(auth))
+	}
+
+	return opts, nil
+}
+
+// platformImage is the image built for a single platform of an extension image.
+type platformImage struct {
+	Platform string
+	Digest   string
+	Image    containerregistryv1.Image
+}
+
+// getPlatformImages returns the images of every platform of an image ref.
+// Attestation manifests attached to an image index are skipped.
+func getPlatformImages(imageRef string, opts ...remote.Option) ([]platformImage, error) {
+	ref, err := name.ParseReference(imageRef, name.Insecure)
+	if err != nil {
+		return nil, err
+	}
+
+	desc, err := remote.Get(ref, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	if !desc.MediaType.IsIndex() {
+		img, err := desc.Image()
+		if err != nil {
+			return nil, err
+		}
+		configFile, err := img.ConfigFile()
+		if err != nil {
+			return nil, err
+		}
+		return []platformImage{{
+			Platform: formatPlatform(configFile.Platform()),
+			Digest:   desc.Digest.String(),
+			Image:    img,
+		}}, nil
+	}
+
+	index, err := desc.ImageIndex()
+	if err != nil {
+		return nil, err
+	}
+	indexManifest, err := index.IndexManifest()
+	if err != nil {
+		return nil, err
+	}
+
+	var images []platformImage
+	for _, manifest := range indexManifest.Manifests {
+		if manifest.Platform == nil || manifest.Platform.OS == "unknown" {
+			continue
+		}
+		img, err := index.Image(manifest.Digest)
+		if err != nil {
+			return nil, fmt.Errorf("while fetching %s image: %w", formatPlatform(manifest.Platform), err)
+		}
+		images = append(images, platformImage{
+			Platform: formatPlatform(manifest.Platform),
+			Digest:   manifest.Digest.String(),
+			Image:    img,
+		})
+	}
+	if len(images) == 0 {
+		return nil, fmt.Errorf("no platform images found in %s", imageRef)
+	}
+
+	return images, nil
+}
+
+// getIndexManifests returns the index manifest of an image ref, together with
+// every manifest it references keyed by digest, attestation manifests included.
+func getIndexManifests(
+	imageRef string,
+	opts ...remote.Option,
+) (*containerregistryv1.IndexManifest, map[string]*containerregistryv1.Manifest, error) {
+	ref, err := name.ParseReference(imageRef, name.Insecure)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	index, err := remote.Index(ref, opts...)
+	if err != nil {
+		return nil, nil, err
+	}
+	indexManifest, err := index.IndexManifest()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	manifests := make(map[string]*containerregistryv1.Manifest, len(indexManifest.Manifests))
+	for _, descriptor := range indexManifest.Manifests {
+		img, err := index.Image(descriptor.Digest)
+		if err != nil {
+			return nil, nil, fmt.Errorf("while fetching manifest %s: %w", descriptor.Digest, err)
+		}
+		manifest, err := img.Manifest()
+		if err != nil {
+			return nil, nil, fmt.Errorf("while fetching manifest %s: %w", descriptor.Digest, err)
+		}
+		manifests[descriptor.Digest.String()] = manifest
+	}
+
+	return indexManifest, manifests, nil
+}
+
+// baseImageContent holds what the verification of an extension image needs
+// from its base image, for a given platform.
+type baseImageContent struct {
+	// Libraries is the set of shared objects available in the dynamic linker search path
+	Libraries map[string]bool
+	// JIT reports whether PostgreSQL ships the LLVM JIT provider
+	JIT bool
+	// Extensions are the SQL names of the extensions shipped with PostgreSQL
+	Extensions []string
+}
+
+// getBaseImageContents returns, for each platform of a base image, the shared
+// objects available in the dynamic linker search path, whether JIT is available
+// and the extensions shipped with PostgreSQL.
+// Base images are public, so no authentication is used to fetch them.
+func getBaseImageContents(ctx context.Context, baseImage string) (map[string]*baseImageContent, error) {
+	images, err := getPlatformImages(baseImage, remote.WithContext(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("while fetching base image %s: %w", baseImage, err)
+	}
+
+	contents := make(map[string]*baseImageContent, len(images))
+	for _, image := range images {
+		fsys, err := readImageFS(image.Image, nil)
+		if err != nil {
+			return nil, fmt.Errorf("base image %s (%s): %w", baseImage, image.Platform, err)
+		}
+		contents[image.Platform] = &baseImageContent{
+			Libraries:  baseImageLibraries(fsys, image.Platform),
+			JIT:        hasJITProvider(fsys),
+			Extensions: baseImageExtensions(fsys),
+		}
+	}
+
+	return contents, nil
+}
+
+// formatPlatform returns the "os/arch" representation of a platform, as used
+// in docker-bake.hcl. The default "v8" variant of arm64 is omitted.
+func formatPlatform(platform *containerregistryv1.Platform) string {
+	if platform == nil {
+		return ""
+	}
+	if platform.Architecture == "arm64" && platform.Variant == "v8" {
+		return platform.OS + "/" + platform.Architecture
+	}
+
+	return platform.String()
+}
+
 // parseImageCoordinates extracts the distribution and PostgreSQL major version
 // from the extension image annotations. Every image carries them, and they are
 // used to resolve the images of any required extensions for the same
@@ -121,6 +350,37 @@ func getExtensionImage(metadata *extensionMetadata, distribution string, pgMajor
 	return image, nil
 }
 
+// latestTimestampedTag returns the tag with the most recent timestamp among the
+// timestamped tags (<version>-<timestamp>-<pgMajor>-<distribution>) of the
+// given extension version, distribution and pgMajor, or an empty string if
+// there is none. An empty version matches any extension version.
+func latestTimestampedTag(tags []string, version string, distribution string, pgMajor int) string {
+	versionPattern := ".+"
+	if version != "" {
+		versionPattern = regexp.QuoteMeta(version)
+	}
+	re := regexp.MustCompile(
+		fmt.Sprintf(`^%s-(\d{12})-%d-%s$`,
+			versionPattern,
+			pgMajor,
+			regexp.QuoteMeta(distribution),
+		),
+	)
+
+	var latestTag, latestTimestamp string
+	for _, tag := range tags {
+		matches := re.FindStringSubmatch(tag)
+		if matches == nil {
+			continue
+		}
+		if matches[1] > latestTimestamp {
+			latestTag, latestTimestamp = tag, matches[1]
+		}
+	}
+
+	return latestTag
+}
+
 // getExtensionImageWithTimestamp returns the extension image with the latest timestamp
 // for a given distribution and pgMajor.
 func getExtensionImageWithTimestamp(metadata *extensionMetadata, distribution string, pgMajor int) (string, error) {
@@ -135,20 +395,7 @@ func getExtensionImageWithTimestamp(metadata *extensionMetadata, distribution st
 		return "", fmt.Errorf("while extracting extension version for %s: %w", metadata.Name, err)
 	}
 
-	re := regexp.MustCompile(
-		fmt.Sprintf(`^%s-(\d{12})-%d-%s$`,
-			regexp.QuoteMeta(version),
-			pgMajor,
-			distribution,
-		),
-	)
-
-	var latestTag string
-	for _, tag := range tags {
-		if re.MatchString(tag) && tag > latestTag {
-			latestTag = tag
-		}
-	}
+	latestTag := latestTimestampedTag(tags, version, distribution, pgMajor)
 	if latestTag == "" {
 		return "", fmt.Errorf(
 			"no image found for image %s (version=%s pgMajor=%d os=%s)",
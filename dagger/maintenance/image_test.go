@@ -79,3 +79,29 @@ func TestParseImageCoordinates(t *testing.T) {
 		})
 	}
 }
+
+func TestLatestTimestampedTag(t *testing.T) {
+	tags := []string{
+		"0.8.0-202501010000-18-trixie",
+		"0.8.1-202503010000-18-trixie",
+		"0.8.1-202504010000-17-trixie",
+		"0.8.1-202505010000-18-bookworm",
+		"0.8.10-202502010000-18-trixie",
+		"0.8.1-18-trixie",
+		"0.8.1",
+	}
+
+	// The most recent timestamp wins, even over a lexically greater version
+	if got := latestTimestampedTag(tags, "", "trixie", 18); got != "0.8.1-202503010000-18-trixie" {
+		t.Errorf("latestTimestampedTag() = %q", got)
+	}
+	if got := latestTimestampedTag(tags, "0.8.0", "trixie", 18); got != "0.8.0-202501010000-18-trixie" {
+		t.Errorf("latestTimestampedTag() = %q", got)
+	}
+	if got := latestTimestampedTag(tags, "0.8.1", "bookworm", 17); got != "" {
+		t.Errorf("latestTimestampedTag() = %q, expected no tag", got)
+	}
+	if got := latestTimestampedTag(tags, "", "trixie", 16); got != "" {
+		t.Errorf("latestTimestampedTag() = %q, expected no tag", got)
+	}
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"archive/tar"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"slices"
+	"strings"
+
+	containerregistryv1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+)
+
+// imageFile is an entry of the file system of an extension image.
+type imageFile struct {
+	// Name is the clean path of the entry, relative to the image root
+	Name     string
+	Size     int64
+	Mode     fs.FileMode
+	Linkname string
+	// Data is only loaded for the regular files selected when reading the image
+	Data []byte
+}
+
+// imageFS is the flattened file system of a single platform image.
+type imageFS struct {
+	files map[string]*imageFile
+}
+
+// readImageFS flattens the layers of an image into an imageFS.
+// The content of a regular file is loaded only when loadData reports true
+// for its name; loadData can be nil to skip loading any content.
+func readImageFS(img containerregistryv1.Image, loadData func(name string) bool) (*imageFS, error) {
+	rc := mutate.Extract(img)
+	defer func() {
+		_ = rc.Close()
+	}()
+
+	fsys := &imageFS{files: make(map[string]*imageFile)}
+	tr := tar.NewReader(rc)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("while reading image layers: %w", err)
+		}
+
+		fileName := cleanImagePath(hdr.Name)
+		if fileName == "" {
+			continue
+		}
+
+		file := &imageFile{
+			Name:     fileName,
+			Size:     hdr.Size,
+			Mode:     hdr.FileInfo().Mode(),
+			Linkname: hdr.Linkname,
+		}
+		if hdr.Typeflag == tar.TypeReg && loadData != nil && loadData(fileName) {
+			file.Data, err = io.ReadAll(tr)
+			if err != nil {
+				return nil, fmt.Errorf("while reading %s: %w", fileName, err)
+			}
+		}
+		fsys.add(file)
+	}
+
+	return fsys, nil
+}
+
+// cleanImagePath normalises a path of a layer entry, making it relative
+// to the image root.
+func cleanImagePath(name string) string {
+	name = strings.TrimPrefix(path.Clean("/"+name), "/")
+	if name == "." {
+		return ""
+	}
+
+	return name
+}
+
+// add stores a file in the file system, creating its missing parent directories.
+func (f *imageFS) add(file *imageFile) {
+	f.files[file.Name] = file
+	for dir := path.Dir(file.Name); dir != "."; dir = path.Dir(dir) {
+		if _, ok := f.files[dir]; ok {
+			continue
+		}
+		f.files[dir] = &imageFile{Name: dir, Mode: fs.ModeDir | 0o755}
+	}
+}
+
+// file returns the entry with the given name, or nil if it doesn't exist.
+func (f *imageFS) file(name string) *imageFile {
+	return f.files[cleanImagePath(name)]
+}
+
+// exists reports whether an entry with the given name exists.
+func (f *imageFS) exists(name string) bool {
+	return f.file(name) != nil
+}
+
+// isDir reports whether the entry with the given name exists and is a directory.
+func (f *imageFS) isDir(name string) bool {
+	file := f.file(name)
+	return file != nil && file.Mode.IsDir()
+}
+
+// glob returns the sorted names of the entries matching the pattern,
+// with the syntax of path.Match.
+func (f *imageFS) glob(pattern string) []string {
+	pattern = cleanImagePath(pattern)
+
+	var names []string
+	for name := range f.files {
+		if matched, _ := path.Match(pattern, name); matched {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+
+	return names
+}
+
+// walk returns the entries under the given directory, sorted by name.
+func (f *imageFS) walk(dir string) []*imageFile {
+	prefix := cleanImagePath(dir) + "/"
+
+	var files []*imageFile
+	for name, file := range f.files {
+		if strings.HasPrefix(name, prefix) {
+			files = append(files, file)
+		}
+	}
+	slices.SortFunc(files, func(a, b *imageFile) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	return files
+}
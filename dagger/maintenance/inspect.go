@@ -0,0 +1,187 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/google/go-containerregistry/pkg/v1/partial"
+	ocispecv1 "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// inspectedDirs are the directories of an extension image listed by Inspect.
+var inspectedDirs = []string{"lib", "share", systemDir}
+
+// imageSummary is the summary of an extension image produced by Inspect.
+type imageSummary struct {
+	Reference    string            `json:"reference"`
+	Version      string            `json:"version,omitempty"`
+	SQLVersion   string            `json:"sqlVersion,omitempty"`
+	BaseImage    string            `json:"baseImage,omitempty"`
+	Distribution string            `json:"distribution,omitempty"`
+	PgMajor      string            `json:"pgMajor,omitempty"`
+	Annotations  map[string]string `json:"annotations,omitempty"`
+	Platforms    []platformSummary `json:"platforms"`
+}
+
+// platformSummary is the summary of a single platform image.
+type platformSummary struct {
+	Platform         string        `json:"platform"`
+	Digest           string        `json:"digest"`
+	CompressedSize   int64         `json:"compressedSize"`
+	UncompressedSize int64         `json:"uncompressedSize"`
+	Layers           int           `json:"layers"`
+	Files            []fileSummary `json:"files"`
+	Licenses         []string      `json:"licenses"`
+}
+
+// fileSummary is an entry of the file tree of an extension image.
+type fileSummary struct {
+	Path     string `json:"path"`
+	Size     int64  `json:"size"`
+	Linkname string `json:"linkname,omitempty"`
+}
+
+// newImageSummary creates the summary of an extension image from its annotations.
+func newImageSummary(imageRef string, annotations map[string]string) *imageSummary {
+	return &imageSummary{
+		Reference:    imageRef,
+		Version:      annotations[ocispecv1.AnnotationVersion],
+		SQLVersion:   annotations[AnnotationImageSQLVersion],
+		BaseImage:    annotations[AnnotationImageBaseName],
+		Distribution: annotations[AnnotationImageBaseOS],
+		PgMajor:      annotations[AnnotationImageBasePgMajor],
+		Annotations:  annotations,
+	}
+}
+
+// summarizePlatformImage computes the sizes of a platform image and lists its
+// file tree and the bundled licenses.
+func summarizePlatformImage(image platformImage) (*platformSummary, error) {
+	summary := &platformSummary{
+		Platform: image.Platform,
+		Digest:   image.Digest,
+	}
+
+	layers, err := image.Image.Layers()
+	if err != nil {
+		return nil, err
+	}
+	summary.Layers = len(layers)
+	for _, layer := range layers {
+		size, err := layer.Size()
+		if err != nil {
+			return nil, err
+		}
+		summary.CompressedSize += size
+
+		uncompressedSize, err := partial.UncompressedSize(layer)
+		if err != nil {
+			return nil, err
+		}
+		summary.UncompressedSize += uncompressedSize
+	}
+
+	fsys, err := readImageFS(image.Image, nil)
+	if err != nil {
+		return nil, err
+	}
+	summarizeImageFS(summary, fsys)
+
+	return summary, nil
+}
+
+// summarizeImageFS lists the file tree of the inspected directories and the
+// license files bundled in an image.
+func summarizeImageFS(summary *platformSummary, fsys *imageFS) {
+	for _, dir := range inspectedDirs {
+		for _, file := range fsys.walk(dir) {
+			if file.Mode.IsDir() {
+				continue
+			}
+			summary.Files = append(summary.Files, fileSummary{
+				Path:     file.Name,
+				Size:     file.Size,
+				Linkname: file.Linkname,
+			})
+		}
+	}
+
+	for _, file := range fsys.walk(licensesDir) {
+		if !file.Mode.IsDir() {
+			summary.Licenses = append(summary.Licenses, file.Name)
+		}
+	}
+}
+
+// renderImageSummary renders the summary of an image in the given format,
+// either "text" or "json".
+func renderImageSummary(summary *imageSummary, format string) (string, error) {
+	switch format {
+	case "json":
+		data, err := json.MarshalIndent(summary, "", "  ")
+		if err != nil {
+			return "", err
+		}
+		return string(data) + "\n", nil
+	case "text":
+		return formatImageSummary(summary), nil
+	}
+
+	return "", fmt.Errorf("unsupported format %q, must be either text or json", format)
+}
+
+// formatImageSummary renders the human-readable summary of an image.
+func formatImageSummary(summary *imageSummary) string {
+	var out strings.Builder
+	w := tabwriter.NewWriter(&out, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintf(w, "Image:\t%s\n", summary.Reference)
+	fmt.Fprintf(w, "Version:\t%s\n", summary.Version)
+	fmt.Fprintf(w, "SQL version:\t%s\n", summary.SQLVersion)
+	fmt.Fprintf(w, "Base image:\t%s\n", summary.BaseImage)
+	fmt.Fprintf(w, "Distribution:\t%s\n", summary.Distribution)
+	fmt.Fprintf(w, "PostgreSQL major:\t%s\n", summary.PgMajor)
+	_ = w.Flush()
+
+	for _, platform := range summary.Platforms {
+		fmt.Fprintf(&out, "\nPlatform %s (%s)\n", platform.Platform, platform.Digest)
+		fmt.Fprintf(&out, "  Layers: %d, compressed size: %s, uncompressed size: %s\n",
+			platform.Layers, formatSize(platform.CompressedSize), formatSize(platform.UncompressedSize))
+
+		fmt.Fprintf(&out, "  Files:\n")
+		w := tabwriter.NewWriter(&out, 0, 0, 2, ' ', 0)
+		for _, file := range platform.Files {
+			if file.Linkname != "" {
+				fmt.Fprintf(w, "    %s\t-> %s\n", file.Path, file.Linkname)
+				continue
+			}
+			fmt.Fprintf(w, "    %s\t%s\n", file.Path, formatSize(file.Size))
+		}
+		_ = w.Flush()
+
+		fmt.Fprintf(&out, "  Licenses:\n")
+		for _, license := range platform.Licenses {
+			fmt.Fprintf(&out, "    %s\n", license)
+		}
+	}
+
+	return out.String()
+}
+
+// formatSize renders a size in bytes with a binary unit prefix.
+func formatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+
+	return fmt.Sprintf("%.1f %ciB", float64(size)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/crane"
+)
+
+func TestSummarizePlatformImage(t *testing.T) {
+	img, err := crane.Image(map[string][]byte{
+		"lib/vector.so":                              []byte("ELF"),
+		"share/extension/vector.control":             []byte("default_version = '0.8.6'"),
+		"licenses/postgresql-18-pgvector/copyright":  []byte("copyright"),
+		"usr/share/doc/postgresql-18-pgvector/notes": []byte("not listed"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	summary, err := summarizePlatformImage(platformImage{Platform: "linux/amd64", Image: img})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if summary.Layers != 1 {
+		t.Errorf("layers: got %d, want 1", summary.Layers)
+	}
+	if summary.CompressedSize == 0 || summary.UncompressedSize == 0 {
+		t.Errorf("sizes: got %d compressed, %d uncompressed", summary.CompressedSize, summary.UncompressedSize)
+	}
+
+	want := []fileSummary{
+		{Path: "lib/vector.so", Size: 3},
+		{Path: "share/extension/vector.control", Size: 25},
+	}
+	if len(summary.Files) != len(want) {
+		t.Fatalf("files: got %v, want %v", summary.Files, want)
+	}
+	for i := range want {
+		if summary.Files[i] != want[i] {
+			t.Errorf("file %d: got %v, want %v", i, summary.Files[i], want[i])
+		}
+	}
+
+	if len(summary.Licenses) != 1 || summary.Licenses[0] != "licenses/postgresql-18-pgvector/copyright" {
+		t.Errorf("licenses: got %v", summary.Licenses)
+	}
+}
+
+func TestRenderImageSummary(t *testing.T) {
+	summary := newImageSummary("ghcr.io/cloudnative-pg/pgvector:0.8.6-18-trixie", map[string]string{
+		"org.opencontainers.image.version": "0.8.6",
+		AnnotationImageSQLVersion:          "0.8.6",
+		AnnotationImageBaseOS:              "trixie",
+		AnnotationImageBasePgMajor:         "18",
+	})
+	summary.Platforms = []platformSummary{{
+		Platform:         "linux/amd64",
+		CompressedSize:   2048,
+		UncompressedSize: 3 * 1024 * 1024,
+		Layers:           3,
+		Files: []fileSummary{
+			{Path: "lib/vector.so", Size: 100},
+			{Path: "system/libfoo.so.1", Linkname: "libfoo.so.1.2"},
+		},
+	}}
+
+	text, err := renderImageSummary(summary, "text")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, want := range []string{
+		"SQL version:       0.8.6",
+		"Platform linux/amd64",
+		"Layers: 3, compressed size: 2.0 KiB, uncompressed size: 3.0 MiB",
+		"lib/vector.so       100 B",
+		"system/libfoo.so.1  -> libfoo.so.1.2",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("text summary doesn't contain %q:\n%s", want, text)
+		}
+	}
+
+	out, err := renderImageSummary(summary, "json")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var decoded imageSummary
+	if err := json.Unmarshal([]byte(out), &decoded); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if decoded.Distribution != "trixie" || len(decoded.Platforms) != 1 {
+		t.Errorf("unexpected decoded summary: %+v", decoded)
+	}
+
+	if _, err := renderImageSummary(summary, "yaml"); err == nil {
+		t.Error("expected an error for an unsupported format")
+	}
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+)
+
+// maxLabelLength is the maximum length of a label value accepted by ghcr.io
+const maxLabelLength = 255
+
+// licenseReport is the license compliance report of an extension image
+// produced by LicenseReport.
+type licenseReport struct {
+	Reference        string             `json:"reference"`
+	DeclaredLicenses []string           `json:"declaredLicenses"`
+	LicenseLabel     string             `json:"licenseLabel"`
+	Violations       []string           `json:"violations,omitempty"`
+	Platforms        []platformLicenses `json:"platforms"`
+}
+
+// platformLicenses holds the licenses bundled in a single platform image.
+type platformLicenses struct {
+	Platform string `json:"platform"`
+	// CopyrightFiles are the files bundled in the licenses directory
+	CopyrightFiles []string `json:"copyrightFiles"`
+	// UnlicensedLibraries are the system libraries without a matching licenses entry
+	UnlicensedLibraries []string `json:"unlicensedLibraries,omitempty"`
+}
+
+// newLicenseReport creates the license report of an extension image from its
+// metadata, checking the length of the resulting OCI license label.
+func newLicenseReport(imageRef string, metadata *extensionMetadata) *licenseReport {
+	report := &licenseReport{
+		Reference:        imageRef,
+		DeclaredLicenses: metadata.Licenses,
+		LicenseLabel:     strings.Join(metadata.Licenses, " AND "),
+	}
+	if len(report.DeclaredLicenses) == 0 {
+		report.Violations = append(report.Violations, "no license declared in metadata")
+	}
+	if len(report.LicenseLabel) > maxLabelLength {
+		report.Violations = append(report.Violations, fmt.Sprintf(
+			"license label is %d characters long, the registry accepts at most %d",
+			len(report.LicenseLabel), maxLabelLength))
+	}
+
+	return report
+}
+
+// licensedPackages returns the names of the packages with an entry in the
+// licenses directory of an image.
+func licensedPackages(fsys *imageFS) []string {
+	var packages []string
+	for _, file := range fsys.walk(licensesDir) {
+		dir := path.Dir(file.Name)
+		if !file.Mode.IsDir() && path.Dir(dir) == licensesDir && !slices.Contains(packages, path.Base(dir)) {
+			packages = append(packages, path.Base(dir))
+		}
+	}
+
+	return packages
+}
+
+// summarizeLicenses lists the copyright files bundled in an image and the
+// system libraries, shipped in the ld_library_path directories, for which no
+// package in the licenses directory matches.
+func summarizeLicenses(platform string, fsys *imageFS, metadata *extensionMetadata) platformLicenses {
+	summary := platformLicenses{Platform: platform}
+	for _, file := range fsys.walk(licensesDir) {
+		if !file.Mode.IsDir() {
+			summary.CopyrightFiles = append(summary.CopyrightFiles, file.Name)
+		}
+	}
+
+	packages := licensedPackages(fsys)
+	for _, names := range systemLibraryNames(fsys, metadata) {
+		if !slices.ContainsFunc(names, func(name string) bool {
+			return slices.ContainsFunc(packages, func(pkg string) bool {
+				return libraryMatchesPackage(name, pkg)
+			})
+		}) {
+			summary.UnlicensedLibraries = append(summary.UnlicensedLibraries, names[0])
+		}
+	}
+	slices.Sort(summary.UnlicensedLibraries)
+
+	return summary
+}
+
+// systemLibraryNames returns, for each shared object file shipped in the
+// ld_library_path directories, the names it is known by: its file name,
+// the symbolic links pointing to it and its DT_SONAME. The file name comes first.
+func systemLibraryNames(fsys *imageFS, metadata *extensionMetadata) map[string][]string {
+	libraries := make(map[string][]string)
+	for _, dir := range metadata.LdLibraryPath {
+		for _, name := range fsys.glob(path.Join(dir, "*.so*")) {
+			file := fsys.file(name)
+			if file.Mode.IsRegular() && file.Linkname == "" {
+				libraries[file.Name] = append([]string{path.Base(file.Name)}, libraries[file.Name]...)
+				if object, err := parseELF(file.Data); err == nil && object.Soname != "" {
+					libraries[file.Name] = append(libraries[file.Name], object.Soname)
+				}
+				continue
+			}
+			if target := resolveLink(fsys, file); target != nil {
+				libraries[target.Name] = append(libraries[target.Name], path.Base(name))
+			}
+		}
+	}
+
+	return libraries
+}
+
+// libraryMatchesPackage reports whether a shared object is likely shipped by
+// a Debian package, following the library packaging policy: libfoo.so.1 is
+// shipped by libfoo1 and libfoo2.so.0 by libfoo2-0, optionally followed by a
+// suffix (e.g. libgeos-c1t64, libjpeg62-turbo).
+func libraryMatchesPackage(library string, pkg string) bool {
+	stem, version, ok := strings.Cut(library, ".so")
+	if !ok || stem == "" {
+		return false
+	}
+	stem = strings.ToLower(strings.ReplaceAll(stem, "_", "-"))
+	version = strings.TrimPrefix(version, ".")
+
+	expected := stem
+	if version != "" {
+		// Only the first component of a full version (libfoo.so.1.2.3) is the soversion
+		if dot := strings.IndexByte(version, '.'); dot > 0 && strings.Count(version, ".") > 1 {
+			version = version[:dot]
+		}
+		if stem[len(stem)-1] >= '0' && stem[len(stem)-1] <= '9' {
+			expected += "-"
+		}
+		expected += version
+	}
+
+	if !strings.HasPrefix(pkg, expected) {
+		return false
+	}
+	suffix := pkg[len(expected):]
+
+	return suffix == "" || (suffix[0] != '.' && (suffix[0] < '0' || suffix[0] > '9'))
+}
+
+// renderLicenseReport renders a license report in JSON.
+func renderLicenseReport(report *licenseReport) (string, error) {
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return "", err
+	}
+
+	return string(data) + "\n", nil
+}
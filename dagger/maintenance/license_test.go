@@ -0,0 +1,71 @@
+package main
+
+import (
+	"io/fs"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestLibraryMatchesPackage(t *testing.T) {
+	tests := []struct {
+		library string
+		pkg     string
+		want    bool
+	}{
+		{library: "libgeos_c.so.1", pkg: "libgeos-c1t64", want: true},
+		{library: "libgeos_c.so.1.19.0", pkg: "libgeos-c1t64", want: true},
+		{library: "libproj.so.25", pkg: "libproj25", want: true},
+		{library: "libjpeg.so.62", pkg: "libjpeg62-turbo", want: true},
+		{library: "libSvtAv1Enc.so.2", pkg: "libsvtav1enc2", want: true},
+		{library: "librav1e.so.0.7", pkg: "librav1e0.7", want: true},
+		{library: "libgav1.so.1", pkg: "libgav1-1", want: true},
+		{library: "libproj.so.2", pkg: "libproj25", want: false},
+		{library: "libgdal.so.36", pkg: "libgeos-c1t64", want: false},
+		{library: "README", pkg: "libgeos-c1t64", want: false},
+	}
+
+	for _, tt := range tests {
+		if got := libraryMatchesPackage(tt.library, tt.pkg); got != tt.want {
+			t.Errorf("libraryMatchesPackage(%q, %q) = %v, want %v", tt.library, tt.pkg, got, tt.want)
+		}
+	}
+}
+
+func TestSummarizeLicenses(t *testing.T) {
+	metadata := &extensionMetadata{LdLibraryPath: []string{"system"}}
+	fsys := newTestImageFS(t, map[string]string{
+		"system/libgeos_c.so.1.19.0":                 "",
+		"system/libgdal.so.36.3.10.3":                "",
+		"licenses/libgeos-c1t64/copyright":           "",
+		"licenses/postgresql-18-postgis-3/copyright": "",
+	})
+	fsys.add(&imageFile{
+		Name:     "system/libgeos_c.so.1",
+		Mode:     fs.ModeSymlink | 0o777,
+		Linkname: "libgeos_c.so.1.19.0",
+	})
+
+	summary := summarizeLicenses("linux/amd64", fsys, metadata)
+
+	wantFiles := []string{"licenses/libgeos-c1t64/copyright", "licenses/postgresql-18-postgis-3/copyright"}
+	if !slices.Equal(summary.CopyrightFiles, wantFiles) {
+		t.Errorf("CopyrightFiles: got %v, want %v", summary.CopyrightFiles, wantFiles)
+	}
+	wantUnlicensed := []string{"libgdal.so.36.3.10.3"}
+	if !slices.Equal(summary.UnlicensedLibraries, wantUnlicensed) {
+		t.Errorf("UnlicensedLibraries: got %v, want %v", summary.UnlicensedLibraries, wantUnlicensed)
+	}
+}
+
+func TestNewLicenseReport(t *testing.T) {
+	report := newLicenseReport("example", &extensionMetadata{Licenses: []string{"GPL-2.0-or-later", "MIT"}})
+	if report.LicenseLabel != "GPL-2.0-or-later AND MIT" || len(report.Violations) != 0 {
+		t.Errorf("got label %q and violations %v", report.LicenseLabel, report.Violations)
+	}
+
+	long := newLicenseReport("example", &extensionMetadata{Licenses: slices.Repeat([]string{"Apache-2.0"}, 20)})
+	if len(long.Violations) != 1 || !strings.Contains(long.Violations[0], "characters long") {
+		t.Errorf("expected a label length violation, got %v", long.Violations)
+	}
+}
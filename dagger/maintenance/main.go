@@ -4,7 +4,6 @@
 package main
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
@@ -15,12 +14,9 @@ import (
 	"sort"
 	"strconv"
 	"strings"
-	"text/template"
 	"time"
 
 	"go.yaml.in/yaml/v3"
-	"golang.org/x/text/cases"
-	"golang.org/x/text/language"
 
 	"dagger/maintenance/internal/dagger"
 )
@@ -37,60 +33,136 @@ func (m *Maintenance) UpdateOSLibs(
 	// The target extension to update OS libs for. Defaults to "all".
 	// +default="all"
 	target string,
+	// The maximum number of combinations resolved concurrently
+	// +default=4
+	concurrency int,
 ) (*dagger.Directory, error) {
-	extDir := source
-	if target != "all" {
-		extDir = source.Filter(dagger.DirectoryFilterOpts{
-			Include: []string{path.Join(target, "**")},
-		})
-		hasMetadataFile, err := extDir.Exists(ctx, path.Join(target, metadataFile))
+	targetDirs, err := getOSLibsTargets(ctx, source, target)
+	if err != nil {
+		return nil, err
+	}
+
+	var jobs []*osLibsJob
+	includeDirs := make([]string, 0, len(targetDirs))
+	for _, dir := range targetDirs {
+		targetDir := path.Join(dir, systemLibsDir)
+		includeDirs = append(includeDirs, targetDir)
+
+		metadata, err := parseExtensionMetadata(ctx, source.Directory(dir))
 		if err != nil {
 			return nil, err
 		}
-		if !hasMetadataFile {
-			return nil, fmt.Errorf("not a valid target, metadata.hcl file is missing. Target: %s", target)
+		matrix := buildMatrixFromMetadata(metadata)
+
+		// Remove the files of the combinations dropped from the matrix, so that
+		// the exported directory mirrors it
+		current, err := source.Glob(ctx, path.Join(targetDir, "*"))
+		if err != nil {
+			return nil, err
+		}
+		source = source.WithoutFiles(staleOSLibsFiles(current, matrix))
+
+		for _, combo := range matrix.Combinations {
+			jobs = append(jobs, &osLibsJob{Dir: dir, Metadata: metadata, Combo: combo})
 		}
 	}
 
-	targetExtensions, err := getExtensions(ctx, extDir, WithOSLibsFilter())
-	if err != nil {
+	if err := newOSLibsResolver().resolveAll(ctx, jobs, concurrency); err != nil {
 		return source, err
 	}
-	if len(targetExtensions) == 0 && target != "all" {
-		return nil, fmt.Errorf("the target %q does not require OS Libs update", target)
+
+	files := make(map[string][]*dagger.File, len(targetDirs))
+	for _, job := range jobs {
+		// The files of the combinations not packaged yet are kept as they are,
+		// PlanOSLibs reports them
+		if job.Skipped != "" {
+			continue
+		}
+		jobFiles, err := osLibsFiles(job.Lock)
+		if err != nil {
+			return source, err
+		}
+		files[job.Dir] = append(files[job.Dir], jobFiles...)
+	}
+	for _, dir := range targetDirs {
+		source = source.WithFiles(path.Join(dir, systemLibsDir), files[dir])
 	}
 
-	const systemLibsDir = "system-libs"
-	includeDirs := make([]string, 0, len(targetExtensions))
+	return source.Filter(dagger.DirectoryFilterOpts{
+		Include: includeDirs,
+	}), nil
+}
 
-	for dir, extension := range targetExtensions {
-		targetDir := path.Join(dir, systemLibsDir)
-		includeDirs = append(includeDirs, targetDir)
+// Reports the changes UpdateOSLibs would apply to the system-libs directory of the specified extension(s)
+func (m *Maintenance) PlanOSLibs(
+	ctx context.Context,
+	// The source directory containing the extension folders. Defaults to the current directory
+	// +ignore=["dagger", ".github"]
+	// +defaultPath="/"
+	source *dagger.Directory,
+	// The target extension to plan OS libs updates for. Defaults to "all".
+	// +default="all"
+	target string,
+	// The output format, either "markdown" or "json"
+	// +default="markdown"
+	format string,
+	// The maximum number of combinations resolved concurrently
+	// +default=4
+	concurrency int,
+) (string, error) {
+	targetDirs, err := getOSLibsTargets(ctx, source, target)
+	if err != nil {
+		return "", err
+	}
 
-		matrix, err := parseBuildMatrix(ctx, source, dir)
+	var jobs []*osLibsJob
+	plan := &osLibsPlan{}
+	for _, dir := range targetDirs {
+		metadata, err := parseExtensionMetadata(ctx, source.Directory(dir))
 		if err != nil {
-			return nil, err
+			return "", err
+		}
+		matrix := buildMatrixFromMetadata(metadata)
+
+		current, err := source.Glob(ctx, path.Join(dir, systemLibsDir, "*"))
+		if err != nil {
+			return "", err
 		}
+		plan.Stale = append(plan.Stale, staleOSLibsFiles(current, matrix)...)
 
-		files := make([]*dagger.File, 0, len(matrix.Combinations))
 		for _, combo := range matrix.Combinations {
-			file, err := updateOSLibsOnTarget(
-				ctx,
-				extension,
-				combo.Distribution,
-				combo.MajorVersion,
-			)
-			if err != nil {
-				return source, err
-			}
-			files = append(files, file)
+			jobs = append(jobs, &osLibsJob{Dir: dir, Metadata: metadata, Combo: combo})
 		}
-		source = source.WithFiles(targetDir, files)
 	}
 
-	return source.Filter(dagger.DirectoryFilterOpts{
-		Include: includeDirs,
-	}), nil
+	if err := newOSLibsResolver().resolveAll(ctx, jobs, concurrency); err != nil {
+		return "", err
+	}
+
+	for _, job := range jobs {
+		systemLibs := source.Directory(path.Join(job.Dir, systemLibsDir))
+		current, origin, err := readCurrentOSLibs(ctx, systemLibs, job.Combo.MajorVersion, job.Combo.Distribution)
+		if err != nil {
+			return "", err
+		}
+		if origin != "" {
+			origin = path.Join(job.Dir, systemLibsDir, origin)
+		}
+		if job.Skipped != "" {
+			plan.Combinations = append(plan.Combinations, osLibsComboPlan{
+				Extension:    job.Metadata.Name,
+				Distribution: job.Combo.Distribution,
+				PgMajor:      job.Combo.MajorVersion,
+				Origin:       origin,
+				Skipped:      job.Skipped,
+				Reason:       job.SkipError.Error(),
+			})
+			continue
+		}
+		plan.Combinations = append(plan.Combinations, planOSLibs(current, origin, job.Lock))
+	}
+
+	return renderOSLibsPlan(plan, format)
 }
 
 // Retrieves a list in JSON format of the extensions requiring OS libs updates
@@ -202,6 +274,19 @@ func (m *Maintenance) GenerateTestingValues(
 		return nil, err
 	}
 
+	// Trusted extensions are created as the owner of the database, who isn't a superuser
+	if metadata.CreateExtension {
+		opts, err := registryOptions(ctx, registryUsername, registryPassword)
+		if err != nil {
+			return nil, err
+		}
+		control, err := getImageControl(targetExtensionImage, metadata, opts...)
+		if err != nil {
+			return nil, err
+		}
+		extensionInfos[0].Trusted = control.Trusted
+	}
+
 	extensions := make([]*ExtensionConfiguration, len(extensionInfos))
 	for i, info := range extensionInfos {
 		extensions[i] = info.Configuration
@@ -219,6 +304,7 @@ func (m *Maintenance) GenerateTestingValues(
 		PgImage:                pgImage,
 		Version:                version,
 		CreateExtension:        metadata.CreateExtension,
+		Trusted:                extensionInfos[0].Trusted,
 		Extensions:             extensions,
 		DatabaseConfig:         databaseConfig,
 		DatabaseAssertStatus:   databaseAssertStatus,
@@ -233,6 +319,298 @@ func (m *Maintenance) GenerateTestingValues(
 	return result, nil
 }
 
+// Adds a PostgreSQL major to the metadata.hcl files of the specified extension(s)
+func (m *Maintenance) AddMajor(
+	ctx context.Context,
+	// The source directory containing the extension folders. Defaults to the current directory
+	// +ignore=["dagger", ".github"]
+	// +defaultPath="/"
+	source *dagger.Directory,
+	// The PostgreSQL major version to add
+	major string,
+	// The target extension to add the PostgreSQL major to. Defaults to "all".
+	// +default="all"
+	target string,
+	// Skip the extensions not packaged yet for the PostgreSQL major instead of failing
+	// +optional
+	skipUnpackaged bool,
+) (*MajorAddition, error) {
+	if !majorVersionRegex.MatchString(major) {
+		return nil, fmt.Errorf("invalid PostgreSQL major version %q", major)
+	}
+	dirs, err := getTargetDirectories(ctx, source, target)
+	if err != nil {
+		return nil, err
+	}
+
+	result := source
+	added := make(map[string][]string)
+	unpackaged := make(map[string][]string)
+	for _, dir := range dirs {
+		metadata, err := parseExtensionMetadata(ctx, source.Directory(dir))
+		if err != nil {
+			return nil, err
+		}
+		document, err := readMetadataDocument(ctx, source, dir)
+		if err != nil {
+			return nil, err
+		}
+
+		distributions, unpackagedDistributions, err := addMajorToExtension(ctx, document, metadata, major)
+		if err != nil {
+			return nil, fmt.Errorf("extension %s: %w", dir, err)
+		}
+		if len(unpackagedDistributions) > 0 {
+			unpackaged[dir] = unpackagedDistributions
+			continue
+		}
+		if len(distributions) == 0 {
+			continue
+		}
+		added[dir] = distributions
+
+		content, err := document.bytes()
+		if err != nil {
+			return nil, fmt.Errorf("extension %s: %w", dir, err)
+		}
+		result = result.WithNewFile(path.Join(dir, metadataFile), string(content))
+	}
+	if len(unpackaged) > 0 && !skipUnpackaged {
+		return nil, fmt.Errorf("%s, use --skip-unpackaged to skip them", formatUnpackaged(major, unpackaged))
+	}
+
+	return &MajorAddition{
+		Summary: formatMajorAddition(major, added, unpackaged),
+		Changes: result.Changes(source),
+	}, nil
+}
+
+// Removes a Debian distribution from the metadata.hcl files and system-libs directories of the specified extension(s)
+func (m *Maintenance) RemoveDistribution(
+	ctx context.Context,
+	// The source directory containing the extension folders. Defaults to the current directory
+	// +ignore=["dagger", ".github"]
+	// +defaultPath="/"
+	source *dagger.Directory,
+	// The Debian distribution to remove
+	distribution string,
+	// The target extension to remove the distribution from. Defaults to "all".
+	// +default="all"
+	target string,
+) (*dagger.Changeset, error) {
+	// The module itself must stop supporting the distribution first
+	if references := distributionReferences(distribution); len(references) > 0 {
+		return nil, fmt.Errorf("distribution %s is still listed in %s, remove it there first",
+			distribution, strings.Join(references, " and "))
+	}
+
+	dirs, err := getTargetDirectories(ctx, source, target)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := removeFromExtensions(ctx, source, dirs, func(document *metadataDocument) (bool, error) {
+		distributions, err := document.distributionEntries()
+		if err != nil {
+			return false, err
+		}
+		if _, ok := findEntry(distributions, distribution); !ok {
+			return false, nil
+		}
+		return true, document.removeDistribution(distribution)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result.Changes(source), nil
+}
+
+// Removes a PostgreSQL major from the metadata.hcl files and system-libs directories of the specified extension(s)
+func (m *Maintenance) RemoveMajor(
+	ctx context.Context,
+	// The source directory containing the extension folders. Defaults to the current directory
+	// +ignore=["dagger", ".github"]
+	// +defaultPath="/"
+	source *dagger.Directory,
+	// The PostgreSQL major version to remove
+	major string,
+	// The target extension to remove the PostgreSQL major from. Defaults to "all".
+	// +default="all"
+	target string,
+) (*dagger.Changeset, error) {
+	dirs, err := getTargetDirectories(ctx, source, target)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := removeFromExtensions(ctx, source, dirs, func(document *metadataDocument) (bool, error) {
+		removed, err := document.removeMajor(major)
+		return len(removed) > 0, err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result.Changes(source), nil
+}
+
+// Bumps the package and SQL versions in the metadata.hcl files of the specified extension(s) to the newest ones in the pgdg repository
+func (m *Maintenance) BumpVersions(
+	ctx context.Context,
+	// The source directory containing the extension folders. Defaults to the current directory
+	// +ignore=["dagger", ".github"]
+	// +defaultPath="/"
+	source *dagger.Directory,
+	// The target extension to bump the versions of. Defaults to "all".
+	// +default="all"
+	target string,
+	// URL of a mirror of the pgdg apt repository to query in its place
+	// +optional
+	mirror string,
+) (*VersionBumps, error) {
+	dirs, err := getTargetDirectories(ctx, source, target)
+	if err != nil {
+		return nil, err
+	}
+
+	var bumps []versionBump
+	result := source
+	for _, dir := range dirs {
+		metadata, err := parseExtensionMetadata(ctx, source.Directory(dir))
+		if err != nil {
+			return nil, err
+		}
+		document, err := readMetadataDocument(ctx, source, dir)
+		if err != nil {
+			return nil, err
+		}
+
+		extensionBumps, err := bumpExtensionVersions(ctx, document, metadata, mirror)
+		if err != nil {
+			return nil, fmt.Errorf("extension %s: %w", dir, err)
+		}
+		if len(extensionBumps) == 0 {
+			continue
+		}
+		bumps = append(bumps, extensionBumps...)
+
+		content, err := document.bytes()
+		if err != nil {
+			return nil, fmt.Errorf("extension %s: %w", dir, err)
+		}
+		result = result.WithNewFile(path.Join(dir, metadataFile), string(content))
+	}
+
+	return &VersionBumps{
+		Summary: formatVersionBumps(bumps),
+		Changes: result.Changes(source),
+	}, nil
+}
+
+// Validates the metadata.hcl files of the specified extension(s)
+func (m *Maintenance) Validate(
+	ctx context.Context,
+	// The source directory containing the extension folders. Defaults to the current directory
+	// +ignore=["dagger", ".github"]
+	// +defaultPath="/"
+	source *dagger.Directory,
+	// The target extension to validate. Defaults to "all".
+	// +default="all"
+	target string,
+) (string, error) {
+	dirs, err := getTargetDirectories(ctx, source, target)
+	if err != nil {
+		return "", err
+	}
+
+	validations := make([]*extensionValidation, 0, len(dirs))
+	for _, dir := range dirs {
+		content, err := source.File(path.Join(dir, metadataFile)).Contents(ctx)
+		if err != nil {
+			return "", err
+		}
+		document, err := parseMetadataDocument([]byte(content))
+		if err != nil {
+			return "", fmt.Errorf("extension %s: %w", dir, err)
+		}
+
+		validation := &extensionValidation{Extension: dir}
+		if err := validateMetadataSchema(validation, []byte(content)); err != nil {
+			return "", fmt.Errorf("extension %s: %w", dir, err)
+		}
+		if err := validateRenovateAnnotations(validation, document); err != nil {
+			return "", fmt.Errorf("extension %s: %w", dir, err)
+		}
+		validations = append(validations, validation)
+	}
+
+	report := formatValidations(validations)
+	if slices.ContainsFunc(validations, func(validation *extensionValidation) bool {
+		return len(validation.Problems) > 0
+	}) {
+		return "", fmt.Errorf("validation failed:\n%s", report)
+	}
+
+	return report, nil
+}
+
+// Formats the metadata.hcl files of the specified extension(s) in their canonical form
+func (m *Maintenance) Fmt(
+	ctx context.Context,
+	// The source directory containing the extension folders. Defaults to the current directory
+	// +ignore=["dagger", ".github"]
+	// +defaultPath="/"
+	source *dagger.Directory,
+	// The target extension to format. Defaults to "all".
+	// +default="all"
+	target string,
+	// Fail if any file isn't in its canonical form, instead of formatting it
+	// +optional
+	check bool,
+) (*dagger.Changeset, error) {
+	dirs, err := getTargetDirectories(ctx, source, target)
+	if err != nil {
+		return nil, err
+	}
+
+	var unformatted []string
+	result := source
+	for _, dir := range dirs {
+		fileName := path.Join(dir, metadataFile)
+		content, err := source.File(fileName).Contents(ctx)
+		if err != nil {
+			return nil, err
+		}
+		formatted, err := formatMetadata([]byte(content))
+		if err != nil {
+			return nil, fmt.Errorf("extension %s: %w", dir, err)
+		}
+		if string(formatted) == content {
+			continue
+		}
+		unformatted = append(unformatted, fileName)
+		result = result.WithNewFile(fileName, string(formatted))
+	}
+
+	if check && len(unformatted) > 0 {
+		return nil, fmt.Errorf("files not in their canonical form, run the fmt command: %s",
+			strings.Join(unformatted, ", "))
+	}
+
+	return result.Changes(source), nil
+}
+
+// Returns the JSON Schema of the metadata.hcl files, for editors
+func (m *Maintenance) MetadataSchema() (string, error) {
+	content, err := json.MarshalIndent(metadataSchema(), "", "  ")
+	if err != nil {
+		return "", err
+	}
+
+	return string(content) + "\n", nil
+}
+
 // Scaffolds a new Postgres extension directory structure
 func (m *Maintenance) Create(
 	ctx context.Context,
@@ -245,7 +623,7 @@ func (m *Maintenance) Create(
 	// +default=["18"]
 	versions []string,
 	// The Debian distributions the extension is supported for
-	// +default=["trixie","bookworm"]
+	// +default=["bookworm","trixie"]
 	distros []string,
 	// The Debian package name for the extension. If the package name contains
 	// the postgres version, it can be templated using the "%version%" placeholder.
@@ -291,20 +669,11 @@ func (m *Maintenance) Create(
 
 	extDir := dag.Directory()
 
-	type Extension struct {
-		Name           string
-		Versions       []string
-		Distros        []string
-		Package        string
-		DefaultVersion int
-		DefaultDistro  string
-	}
-
 	if packageName == "" {
 		packageName = "postgresql-%version%-" + name
 	}
 
-	extension := Extension{
+	extension := scaffoldedExtension{
 		Name:           name,
 		Versions:       versions,
 		Distros:        distros,
@@ -313,30 +682,17 @@ func (m *Maintenance) Create(
 		DefaultDistro:  DefaultDistribution,
 	}
 
-	toTitle := func(s string) string {
-		return cases.Title(language.English).String(s)
-	}
-
-	funcMap := template.FuncMap{
-		"replaceAll": strings.ReplaceAll,
-		"toTitle":    toTitle,
-	}
-
 	executeTemplate := func(fileName string) error {
 		tmplFile := templatesDir.File(fileName + ".tmpl")
 		tmplContent, err := tmplFile.Contents(ctx)
 		if err != nil {
 			return fmt.Errorf("failed to read template file %s.tmpl: %w", fileName, err)
 		}
-		tmpl, err := template.New(fileName).Funcs(funcMap).Parse(tmplContent)
+		content, err := renderExtensionTemplate(fileName, tmplContent, extension)
 		if err != nil {
-			return fmt.Errorf("failed to parse template %s.tmpl: %w", fileName, err)
-		}
-		buf := &bytes.Buffer{}
-		if err := tmpl.Execute(buf, extension); err != nil {
-			return fmt.Errorf("failed to execute template %s.tmpl: %w", fileName, err)
+			return err
 		}
-		extDir = extDir.WithNewFile(fileName, buf.String())
+		extDir = extDir.WithNewFile(fileName, content)
 		return nil
 	}
 
@@ -551,3 +907,759 @@ func (m *Maintenance) GenerateCatalogs(
 
 	return outDir, nil
 }
+
+// Verifies the file system and the shared objects dependencies of an extension
+// image against the extension's metadata
+func (m *Maintenance) VerifyImage(
+	ctx context.Context,
+	// The source directory containing the extension folders. Defaults to the current directory
+	// +ignore=["dagger", ".github"]
+	// +defaultPath="/"
+	source *dagger.Directory,
+	// The target extension to verify
+	target string,
+	// URL reference to the extension image to verify [REPOSITORY[:TAG]]
+	// +optional
+	extensionImage string,
+	// Registry username for authentication (optional)
+	// +optional
+	registryUsername string,
+	// Registry password or token for authentication (optional)
+	// +optional
+	registryPassword *dagger.Secret,
+) (string, error) {
+	image, err := resolveExtensionImage(ctx, source, target, extensionImage, registryUsername, registryPassword)
+	if err != nil {
+		return "", err
+	}
+	metadata := image.Metadata
+	targetExtensionImage := image.Reference
+
+	baseImage := image.Annotations[AnnotationImageBaseName]
+	if baseImage == "" {
+		return "", fmt.Errorf(
+			"extension image %s doesn't have an %q annotation or its value is empty",
+			targetExtensionImage, AnnotationImageBaseName)
+	}
+
+	baseContents, err := getBaseImageContents(ctx, baseImage)
+	if err != nil {
+		return "", err
+	}
+
+	requiredSQLNames, err := requiredExtensionSQLNames(ctx, source, metadata)
+	if err != nil {
+		return "", err
+	}
+
+	opts, err := registryOptions(ctx, registryUsername, registryPassword)
+	if err != nil {
+		return "", err
+	}
+
+	images, err := getPlatformImages(targetExtensionImage, opts...)
+	if err != nil {
+		return "", err
+	}
+
+	loadData := func(name string) bool {
+		return isControlFile(name) || mayContainELF(name)
+	}
+
+	verifications := make([]*imageVerification, 0, len(images))
+	bitcode := make(map[string]map[string]bool, len(images))
+	modules := make(map[string]map[string]bool, len(images))
+	jitPlatforms := make(map[string]bool, len(images))
+	for _, platformImage := range images {
+		fsys, err := readImageFS(platformImage.Image, loadData)
+		if err != nil {
+			return "", fmt.Errorf("extension image %s (%s): %w", targetExtensionImage, platformImage.Platform, err)
+		}
+
+		verification := &imageVerification{Platform: platformImage.Platform}
+		verifyImageFS(verification, fsys, metadata, image.Version.SQL)
+		verifyELFMachines(verification, fsys, metadata)
+		bitcode[platformImage.Platform] = bitcodeModules(verification, fsys, metadata)
+		modules[platformImage.Platform] = loadableModules(fsys, metadata)
+
+		platformContent, ok := baseContents[platformImage.Platform]
+		if ok {
+			if metadata.CreateExtension {
+				verifyControlProperties(verification, fsys, metadata, requiredSQLNames, platformContent.Extensions)
+			}
+			verifyELFDependencies(verification, fsys, metadata, platformContent.Libraries)
+			jitPlatforms[platformImage.Platform] = platformContent.JIT
+		} else {
+			verification.addProblem("base image %s is not available for platform %s", baseImage, platformImage.Platform)
+		}
+		verifications = append(verifications, verification)
+	}
+	packageBitcode := make(map[string]bool)
+	if slices.Contains(slices.Collect(maps.Values(jitPlatforms)), true) {
+		files, err := extensionPackageFiles(ctx, pgdgPackageFiles(""), metadata,
+			image.Distribution, strconv.Itoa(image.PgMajor), image.Version.Package)
+		if err != nil {
+			return "", fmt.Errorf("extension image %s: %w", targetExtensionImage, err)
+		}
+		packageBitcode = packageBitcodeModules(files)
+	}
+	verifyBitcodeCompleteness(verifications, bitcode, modules, packageBitcode, jitPlatforms)
+
+	report := formatVerifications(targetExtensionImage, verifications)
+	if hasProblems(verifications) {
+		return "", fmt.Errorf("extension image %s failed verification:\n%s", targetExtensionImage, report)
+	}
+
+	return report, nil
+}
+
+// Returns the properties declared in the control file of an extension image
+func (m *Maintenance) GetExtensionControl(
+	ctx context.Context,
+	// The source directory containing the extension folders. Defaults to the current directory
+	// +ignore=["dagger", ".github"]
+	// +defaultPath="/"
+	source *dagger.Directory,
+	// The target extension
+	target string,
+	// URL reference to the extension image [REPOSITORY[:TAG]]
+	// +optional
+	extensionImage string,
+	// Registry username for authentication (optional)
+	// +optional
+	registryUsername string,
+	// Registry password or token for authentication (optional)
+	// +optional
+	registryPassword *dagger.Secret,
+) (*ExtensionControl, error) {
+	image, err := resolveExtensionImage(ctx, source, target, extensionImage, registryUsername, registryPassword)
+	if err != nil {
+		return nil, err
+	}
+
+	opts, err := registryOptions(ctx, registryUsername, registryPassword)
+	if err != nil {
+		return nil, err
+	}
+
+	return getImageControl(image.Reference, image.Metadata, opts...)
+}
+
+// Verifies the platforms, the OCI annotations and the attestations of a published
+// extension image against the extension's metadata
+func (m *Maintenance) VerifyImageAnnotations(
+	ctx context.Context,
+	// The source directory containing the extension folders. Defaults to the current directory
+	// +ignore=["dagger", ".github"]
+	// +defaultPath="/"
+	source *dagger.Directory,
+	// The target extension to verify
+	target string,
+	// URL reference to the extension image to verify [REPOSITORY[:TAG]]
+	// +optional
+	extensionImage string,
+	// Registry username for authentication (optional)
+	// +optional
+	registryUsername string,
+	// Registry password or token for authentication (optional)
+	// +optional
+	registryPassword *dagger.Secret,
+) (string, error) {
+	image, err := resolveExtensionImage(ctx, source, target, extensionImage, registryUsername, registryPassword)
+	if err != nil {
+		return "", err
+	}
+
+	opts, err := registryOptions(ctx, registryUsername, registryPassword)
+	if err != nil {
+		return "", err
+	}
+
+	index, manifests, err := getIndexManifests(image.Reference, opts...)
+	if err != nil {
+		return "", err
+	}
+
+	verifications := verifyIndexConformance(index, manifests,
+		expectedAnnotations(image.Metadata, image.Version))
+
+	report := formatVerifications(image.Reference, verifications)
+	if hasProblems(verifications) {
+		return "", fmt.Errorf("extension image %s failed verification:\n%s", image.Reference, report)
+	}
+
+	return report, nil
+}
+
+// Prints a summary of an extension image: versions, platforms, sizes, files and licenses
+func (m *Maintenance) Inspect(
+	ctx context.Context,
+	// URL reference to the extension image to inspect [REPOSITORY[:TAG]]
+	extensionImage string,
+	// Registry username for authentication (optional)
+	// +optional
+	registryUsername string,
+	// Registry password or token for authentication (optional)
+	// +optional
+	registryPassword *dagger.Secret,
+	// The output format, either "text" or "json"
+	// +default="text"
+	format string,
+) (string, error) {
+	annotations, err := getImageAnnotations(ctx, extensionImage, registryUsername, registryPassword)
+	if err != nil {
+		return "", err
+	}
+
+	opts, err := registryOptions(ctx, registryUsername, registryPassword)
+	if err != nil {
+		return "", err
+	}
+
+	images, err := getPlatformImages(extensionImage, opts...)
+	if err != nil {
+		return "", err
+	}
+
+	summary := newImageSummary(extensionImage, annotations)
+	for _, image := range images {
+		platformSummary, err := summarizePlatformImage(image)
+		if err != nil {
+			return "", fmt.Errorf("extension image %s (%s): %w", extensionImage, image.Platform, err)
+		}
+		summary.Platforms = append(summary.Platforms, *platformSummary)
+	}
+
+	return renderImageSummary(summary, format)
+}
+
+// Compares the files and the annotations of two extension images, platform by platform
+func (m *Maintenance) DiffImages(
+	ctx context.Context,
+	// URL reference to the extension image to compare from [REPOSITORY[:TAG]]
+	fromImage string,
+	// URL reference to the extension image to compare to [REPOSITORY[:TAG]]
+	toImage string,
+	// Registry username for authentication (optional)
+	// +optional
+	registryUsername string,
+	// Registry password or token for authentication (optional)
+	// +optional
+	registryPassword *dagger.Secret,
+	// The output format, either "markdown" or "json"
+	// +default="markdown"
+	format string,
+) (string, error) {
+	diff := &imageDiff{From: fromImage, To: toImage}
+
+	fromAnnotations, err := getImageAnnotations(ctx, fromImage, registryUsername, registryPassword)
+	if err != nil {
+		return "", err
+	}
+	toAnnotations, err := getImageAnnotations(ctx, toImage, registryUsername, registryPassword)
+	if err != nil {
+		return "", err
+	}
+	diff.Annotations = diffAnnotations(fromAnnotations, toAnnotations)
+
+	opts, err := registryOptions(ctx, registryUsername, registryPassword)
+	if err != nil {
+		return "", err
+	}
+
+	readPlatforms := func(imageRef string) (map[string]*imageFS, error) {
+		images, err := getPlatformImages(imageRef, opts...)
+		if err != nil {
+			return nil, err
+		}
+		platforms := make(map[string]*imageFS, len(images))
+		for _, image := range images {
+			fsys, err := readImageFS(image.Image, nil)
+			if err != nil {
+				return nil, fmt.Errorf("extension image %s (%s): %w", imageRef, image.Platform, err)
+			}
+			platforms[image.Platform] = fsys
+		}
+		return platforms, nil
+	}
+
+	fromPlatforms, err := readPlatforms(fromImage)
+	if err != nil {
+		return "", err
+	}
+	toPlatforms, err := readPlatforms(toImage)
+	if err != nil {
+		return "", err
+	}
+
+	platforms := slices.Sorted(maps.Keys(fromPlatforms))
+	for platform := range toPlatforms {
+		if _, ok := fromPlatforms[platform]; !ok {
+			platforms = append(platforms, platform)
+		}
+	}
+	slices.Sort(platforms)
+
+	for _, platform := range platforms {
+		fromFS, inFrom := fromPlatforms[platform]
+		toFS, inTo := toPlatforms[platform]
+		switch {
+		case !inFrom:
+			diff.Platforms = append(diff.Platforms, platformDiff{Platform: platform, Missing: fromImage})
+		case !inTo:
+			diff.Platforms = append(diff.Platforms, platformDiff{Platform: platform, Missing: toImage})
+		default:
+			diff.Platforms = append(diff.Platforms, diffImageFS(platform, fromFS, toFS))
+		}
+	}
+
+	return renderImageDiff(diff, format)
+}
+
+// Checks that an extension image ships the update scripts needed to upgrade
+// from the SQL version of the latest published image for the same distribution
+// and PostgreSQL major
+func (m *Maintenance) CheckUpgradePath(
+	ctx context.Context,
+	// The source directory containing the extension folders. Defaults to the current directory
+	// +ignore=["dagger", ".github"]
+	// +defaultPath="/"
+	source *dagger.Directory,
+	// The target extension to check
+	target string,
+	// URL reference to the extension image to check [REPOSITORY[:TAG]]
+	// +optional
+	extensionImage string,
+	// Registry username for authentication (optional)
+	// +optional
+	registryUsername string,
+	// Registry password or token for authentication (optional)
+	// +optional
+	registryPassword *dagger.Secret,
+) (string, error) {
+	image, err := resolveExtensionImage(ctx, source, target, extensionImage, registryUsername, registryPassword)
+	if err != nil {
+		return "", err
+	}
+
+	publishedImage, err := getLatestPublishedImage(image.Metadata, image.Distribution, image.PgMajor)
+	if err != nil {
+		return "", err
+	}
+	if publishedImage == "" {
+		return fmt.Sprintf("No image published yet for %s (%s, PostgreSQL %d), skipping\n",
+			image.Metadata.Name, image.Distribution, image.PgMajor), nil
+	}
+
+	publishedAnnotations, err := getImageAnnotations(ctx, publishedImage, registryUsername, registryPassword)
+	if err != nil {
+		return "", err
+	}
+	publishedSQLVersion := publishedAnnotations[AnnotationImageSQLVersion]
+	if publishedSQLVersion == "" {
+		return "", fmt.Errorf(
+			"published image %s doesn't have an %q annotation or its value is empty",
+			publishedImage, AnnotationImageSQLVersion)
+	}
+
+	newSQLVersion := image.Annotations[AnnotationImageSQLVersion]
+	if newSQLVersion == "" {
+		return "", fmt.Errorf(
+			"extension image %s doesn't have an %q annotation or its value is empty",
+			image.Reference, AnnotationImageSQLVersion)
+	}
+	if newSQLVersion == publishedSQLVersion {
+		return fmt.Sprintf("SQL version %s is unchanged since %s, skipping\n",
+			newSQLVersion, publishedImage), nil
+	}
+
+	opts, err := registryOptions(ctx, registryUsername, registryPassword)
+	if err != nil {
+		return "", err
+	}
+
+	images, err := getPlatformImages(image.Reference, opts...)
+	if err != nil {
+		return "", err
+	}
+
+	verifications := make([]*imageVerification, 0, len(images))
+	for _, platformImage := range images {
+		fsys, err := readImageFS(platformImage.Image, nil)
+		if err != nil {
+			return "", fmt.Errorf("extension image %s (%s): %w", image.Reference, platformImage.Platform, err)
+		}
+
+		verification := &imageVerification{Platform: platformImage.Platform}
+		verifyUpgradePath(verification, fsys, image.Metadata, publishedSQLVersion, newSQLVersion)
+		verifications = append(verifications, verification)
+	}
+
+	report := fmt.Sprintf("Upgrade from %s (%s) to %s\n", publishedImage, publishedSQLVersion, newSQLVersion) +
+		formatVerifications(image.Reference, verifications)
+	if hasProblems(verifications) {
+		return "", fmt.Errorf("extension image %s failed upgrade path check:\n%s", image.Reference, report)
+	}
+
+	return report, nil
+}
+
+// Checks the version changes of the metadata.hcl files between two source trees:
+// package versions must not be downgraded and SQL version changes must come
+// with update scripts
+func (m *Maintenance) CheckVersionChanges(
+	ctx context.Context,
+	// The source directory to compare against, such as the base branch
+	base *dagger.Directory,
+	// The source directory containing the extension folders. Defaults to the current directory
+	// +ignore=["dagger", ".github"]
+	// +defaultPath="/"
+	source *dagger.Directory,
+	// The target extension to check. Defaults to "all".
+	// +default="all"
+	target string,
+	// URL of a mirror of the pgdg apt repository to query in its place
+	// +optional
+	mirror string,
+) (string, error) {
+	dirs, err := getTargetDirectories(ctx, source, target)
+	if err != nil {
+		return "", err
+	}
+
+	var changes []versionChange
+	var problems []string
+	for _, dir := range dirs {
+		hasBaseMetadata, err := base.Exists(ctx, path.Join(dir, metadataFile))
+		if err != nil {
+			return "", err
+		}
+		if !hasBaseMetadata {
+			continue
+		}
+		baseMetadata, err := parseExtensionMetadata(ctx, base.Directory(dir))
+		if err != nil {
+			return "", fmt.Errorf("base extension %s: %w", dir, err)
+		}
+		metadata, err := parseExtensionMetadata(ctx, source.Directory(dir))
+		if err != nil {
+			return "", fmt.Errorf("extension %s: %w", dir, err)
+		}
+
+		extensionChanges := diffVersions(metadata.Name, baseMetadata.Versions, metadata.Versions)
+		extensionProblems, err := checkVersionChanges(ctx, metadata, extensionChanges, pgdgPackageFiles(mirror))
+		if err != nil {
+			return "", fmt.Errorf("extension %s: %w", dir, err)
+		}
+		changes = append(changes, extensionChanges...)
+		problems = append(problems, extensionProblems...)
+	}
+
+	report := formatVersionChanges(changes, problems)
+	if len(problems) > 0 {
+		return "", fmt.Errorf("version changes check failed:\n%s", report)
+	}
+
+	return report, nil
+}
+
+// Reports the declared licenses of an extension image, the bundled copyright
+// files and the system libraries without a matching license entry, in JSON
+func (m *Maintenance) LicenseReport(
+	ctx context.Context,
+	// The source directory containing the extension folders. Defaults to the current directory
+	// +ignore=["dagger", ".github"]
+	// +defaultPath="/"
+	source *dagger.Directory,
+	// The target extension to report on
+	target string,
+	// URL reference to the extension image [REPOSITORY[:TAG]]
+	// +optional
+	extensionImage string,
+	// Registry username for authentication (optional)
+	// +optional
+	registryUsername string,
+	// Registry password or token for authentication (optional)
+	// +optional
+	registryPassword *dagger.Secret,
+) (string, error) {
+	image, err := resolveExtensionImage(ctx, source, target, extensionImage, registryUsername, registryPassword)
+	if err != nil {
+		return "", err
+	}
+
+	opts, err := registryOptions(ctx, registryUsername, registryPassword)
+	if err != nil {
+		return "", err
+	}
+
+	images, err := getPlatformImages(image.Reference, opts...)
+	if err != nil {
+		return "", err
+	}
+
+	report := newLicenseReport(image.Reference, image.Metadata)
+	for _, platformImage := range images {
+		fsys, err := readImageFS(platformImage.Image, isSharedObject)
+		if err != nil {
+			return "", fmt.Errorf("extension image %s (%s): %w", image.Reference, platformImage.Platform, err)
+		}
+		report.Platforms = append(report.Platforms, summarizeLicenses(platformImage.Platform, fsys, image.Metadata))
+	}
+
+	return renderLicenseReport(report)
+}
+
+// Exports the packages listed in the SBOM attestations of an extension image, in JSON
+func (m *Maintenance) SBOM(
+	ctx context.Context,
+	// URL reference to the extension image [REPOSITORY[:TAG]]
+	extensionImage string,
+	// Registry username for authentication (optional)
+	// +optional
+	registryUsername string,
+	// Registry password or token for authentication (optional)
+	// +optional
+	registryPassword *dagger.Secret,
+) (string, error) {
+	opts, err := registryOptions(ctx, registryUsername, registryPassword)
+	if err != nil {
+		return "", err
+	}
+
+	sbom, err := readImageSBOM(extensionImage, opts...)
+	if err != nil {
+		return "", err
+	}
+
+	data, err := json.MarshalIndent(sbom, "", "  ")
+	if err != nil {
+		return "", err
+	}
+
+	return string(data) + "\n", nil
+}
+
+// Compares the packages listed in the SBOM attestations of two extension images
+func (m *Maintenance) DiffSBOMs(
+	ctx context.Context,
+	// URL reference to the extension image to compare from [REPOSITORY[:TAG]]
+	fromImage string,
+	// URL reference to the extension image to compare to [REPOSITORY[:TAG]]
+	toImage string,
+	// Registry username for authentication (optional)
+	// +optional
+	registryUsername string,
+	// Registry password or token for authentication (optional)
+	// +optional
+	registryPassword *dagger.Secret,
+	// The output format, either "markdown" or "json"
+	// +default="markdown"
+	format string,
+) (string, error) {
+	opts, err := registryOptions(ctx, registryUsername, registryPassword)
+	if err != nil {
+		return "", err
+	}
+
+	fromSBOM, err := readImageSBOM(fromImage, opts...)
+	if err != nil {
+		return "", err
+	}
+	toSBOM, err := readImageSBOM(toImage, opts...)
+	if err != nil {
+		return "", err
+	}
+
+	fromPlatforms := make(map[string][]sbomPackage, len(fromSBOM.Platforms))
+	for _, platform := range fromSBOM.Platforms {
+		fromPlatforms[platform.Platform] = platform.Packages
+	}
+	toPlatforms := make(map[string][]sbomPackage, len(toSBOM.Platforms))
+	for _, platform := range toSBOM.Platforms {
+		toPlatforms[platform.Platform] = platform.Packages
+	}
+
+	platforms := slices.Sorted(maps.Keys(fromPlatforms))
+	for platform := range toPlatforms {
+		if _, ok := fromPlatforms[platform]; !ok {
+			platforms = append(platforms, platform)
+		}
+	}
+	slices.Sort(platforms)
+
+	diff := &sbomDiff{From: fromImage, To: toImage}
+	for _, platform := range platforms {
+		fromPackages, inFrom := fromPlatforms[platform]
+		toPackages, inTo := toPlatforms[platform]
+		switch {
+		case !inFrom:
+			diff.Platforms = append(diff.Platforms, platformSBOMDiff{Platform: platform, Missing: fromImage})
+		case !inTo:
+			diff.Platforms = append(diff.Platforms, platformSBOMDiff{Platform: platform, Missing: toImage})
+		default:
+			diff.Platforms = append(diff.Platforms, diffSBOMPackages(platform, fromPackages, toPackages))
+		}
+	}
+
+	return renderSBOMDiff(diff, format)
+}
+
+// Reports the known vulnerabilities of the OS libraries bundled in extension
+// images, matched against a snapshot of the Debian security tracker. Packages
+// whose source package isn't recorded, nor known to the tracker, are reported
+// as unresolved. OS libraries text files don't record source packages, so the
+// scan of a text file without a lockfile fails if none of its packages can be
+// resolved: run update-os-libs to generate the lockfiles first
+func (m *Maintenance) ScanOSLibs(
+	ctx context.Context,
+	// The source directory containing the extension folders. Defaults to the current directory
+	// +ignore=["dagger", ".github"]
+	// +defaultPath="/"
+	source *dagger.Directory,
+	// Snapshot of the Debian security tracker (https://security-tracker.debian.org/tracker/data/json)
+	database *dagger.File,
+	// The target extension to scan the OS libraries files of. Defaults to "all".
+	// +default="all"
+	target string,
+	// URL reference to an extension image whose SBOM is scanned in place of the
+	// OS libraries files [REPOSITORY[:TAG]]
+	// +optional
+	extensionImage string,
+	// Registry username for authentication (optional)
+	// +optional
+	registryUsername string,
+	// Registry password or token for authentication (optional)
+	// +optional
+	registryPassword *dagger.Secret,
+	// The output format, either "markdown" or "json"
+	// +default="markdown"
+	format string,
+) (string, error) {
+	data, err := database.Contents(ctx)
+	if err != nil {
+		return "", err
+	}
+	tracker, err := parseSecurityTracker([]byte(data))
+	if err != nil {
+		return "", err
+	}
+
+	var scans []*osLibsScan
+	if extensionImage != "" {
+		annotations, err := getImageAnnotations(ctx, extensionImage, registryUsername, registryPassword)
+		if err != nil {
+			return "", err
+		}
+		distribution, pgMajor, err := parseImageCoordinates(annotations)
+		if err != nil {
+			return "", fmt.Errorf("extension image %s: %w", extensionImage, err)
+		}
+
+		opts, err := registryOptions(ctx, registryUsername, registryPassword)
+		if err != nil {
+			return "", err
+		}
+		sbom, err := readImageSBOM(extensionImage, opts...)
+		if err != nil {
+			return "", err
+		}
+
+		for _, platform := range sbom.Platforms {
+			var packages []osPackage
+			for _, pkg := range platform.Packages {
+				if pkg.Type == "deb" {
+					packages = append(packages, osPackage{
+						Name:    pkg.Name,
+						Version: pkg.Version,
+						Arch:    pkg.Arch,
+						Source:  pkg.Source,
+					})
+				}
+			}
+			scan := &osLibsScan{
+				Extension:    extensionImage,
+				Distribution: distribution,
+				PgMajor:      strconv.Itoa(pgMajor),
+				Origin:       fmt.Sprintf("SBOM (%s)", platform.Platform),
+			}
+			scanPackages(tracker, scan, packages)
+			scans = append(scans, scan)
+		}
+
+		return renderVulnerabilityScans(scans, format)
+	}
+
+	pattern := path.Join("*", "system-libs", "*-os-libs*")
+	if target != "all" {
+		pattern = path.Join(target, "system-libs", "*-os-libs*")
+	}
+	files, err := source.Glob(ctx, pattern)
+	if err != nil {
+		return "", err
+	}
+	if len(files) == 0 {
+		return "", fmt.Errorf("no OS libraries files found for target %q", target)
+	}
+	slices.Sort(files)
+
+	for _, file := range files {
+		extension := strings.SplitN(file, "/", 2)[0]
+
+		// Lockfiles record the source packages of every platform, prefer them
+		if matches := osLibsLockFileRegex.FindStringSubmatch(path.Base(file)); matches != nil {
+			content, err := source.File(file).Contents(ctx)
+			if err != nil {
+				return "", err
+			}
+			lock, err := parseOSLibsLock([]byte(content))
+			if err != nil {
+				return "", fmt.Errorf("while parsing %s: %w", file, err)
+			}
+
+			for _, platform := range lock.sortedPlatforms() {
+				scan := &osLibsScan{
+					Extension:    extension,
+					Distribution: matches[2],
+					PgMajor:      matches[1],
+					Origin:       fmt.Sprintf("%s (%s)", file, platform),
+				}
+				scanPackages(tracker, scan, lock.osPackages(platform))
+				scans = append(scans, scan)
+			}
+			continue
+		}
+
+		matches := osLibsFileRegex.FindStringSubmatch(path.Base(file))
+		if matches == nil {
+			continue
+		}
+		lockFile := path.Join(path.Dir(file), osLibsLockFileName(matches[1], matches[2]))
+		if slices.Contains(files, lockFile) {
+			continue
+		}
+
+		content, err := source.File(file).Contents(ctx)
+		if err != nil {
+			return "", err
+		}
+		packages, err := parseOSLibs(content)
+		if err != nil {
+			return "", fmt.Errorf("while parsing %s: %w", file, err)
+		}
+
+		scan := &osLibsScan{
+			Extension:    extension,
+			Distribution: matches[2],
+			PgMajor:      matches[1],
+			Origin:       file,
+		}
+		scanPackages(tracker, scan, packages)
+		if err := checkResolvedScan(scan); err != nil {
+			return "", fmt.Errorf("%w: generate the lockfile with update-os-libs", err)
+		}
+		scans = append(scans, scan)
+	}
+
+	return renderVulnerabilityScans(scans, format)
+}
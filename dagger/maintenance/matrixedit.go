@@ -0,0 +1,249 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+
+	"dagger/maintenance/internal/dagger"
+)
+
+// majorVersionRegex matches a PostgreSQL major version
+var majorVersionRegex = regexp.MustCompile(`^[1-9][0-9]*$`)
+
+// MajorAddition holds the changes applied by AddMajor
+type MajorAddition struct {
+	// The summary of the extensions the PostgreSQL major was added to, and of
+	// the ones skipped as not packaged yet, in Markdown
+	Summary string
+	// The changes to the metadata.hcl files
+	Changes *dagger.Changeset
+}
+
+// getTargetDirectories returns the directories of the extensions for the
+// given target, either an extension or "all".
+func getTargetDirectories(ctx context.Context, source *dagger.Directory, target string) ([]string, error) {
+	if target != "all" {
+		hasMetadataFile, err := source.Exists(ctx, path.Join(target, metadataFile))
+		if err != nil {
+			return nil, err
+		}
+		if !hasMetadataFile {
+			return nil, fmt.Errorf("not a valid target, metadata.hcl file is missing. Target: %s", target)
+		}
+		return []string{target}, nil
+	}
+
+	extensions, err := getExtensions(ctx, source)
+	if err != nil {
+		return nil, err
+	}
+	return slices.Sorted(maps.Keys(extensions)), nil
+}
+
+// readMetadataDocument reads the metadata.hcl file of an extension for editing.
+func readMetadataDocument(ctx context.Context, source *dagger.Directory, dir string) (*metadataDocument, error) {
+	content, err := source.File(path.Join(dir, metadataFile)).Contents(ctx)
+	if err != nil {
+		return nil, err
+	}
+	document, err := parseMetadataDocument([]byte(content))
+	if err != nil {
+		return nil, fmt.Errorf("extension %s: %w", dir, err)
+	}
+
+	return document, nil
+}
+
+// addMajorToExtension adds a PG major to every distribution of an extension,
+// with the newest package version available in the pgdg repository. The sql
+// version is derived from it with the extractVersion rule of the renovate
+// annotation of the distribution's latest PG major. Distributions already
+// declaring the PG major are left alone. It returns the distributions the PG
+// major was added to, and the ones where the package isn't available yet, in
+// which case the document is left untouched.
+func addMajorToExtension(
+	ctx context.Context,
+	document *metadataDocument,
+	metadata *extensionMetadata,
+	majorVersion string,
+) (added []string, unpackaged []string, err error) {
+	entries, err := document.versionEntries()
+	if err != nil {
+		return nil, nil, err
+	}
+	packages, err := resolvePackages(metadata, majorVersion)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// The entry of the latest PG major of each distribution is used as a
+	// template, the distributions already declaring the PG major are skipped
+	templates := make(map[string]versionEntry)
+	declared := make(map[string]bool)
+	var distributions []string
+	for _, entry := range entries {
+		template, ok := templates[entry.Distribution]
+		if !ok {
+			distributions = append(distributions, entry.Distribution)
+		}
+		if entry.MajorVersion == majorVersion {
+			declared[entry.Distribution] = true
+		}
+		if !ok || compareDigits(entry.MajorVersion, template.MajorVersion) > 0 {
+			templates[entry.Distribution] = entry
+		}
+	}
+	distributions = slices.DeleteFunc(distributions, func(distribution string) bool {
+		return declared[distribution]
+	})
+
+	type addition struct {
+		distribution, packageVersion, sqlVersion string
+	}
+	var additions []addition
+	for _, distribution := range distributions {
+		template := templates[distribution]
+		// The pgdg repository serves every PG major, so the base image of
+		// the template is used as the new major's may not be published yet
+		versions, err := getPgdgVersions(ctx, pgdgContainer(distribution, template.MajorVersion, ""), packages[:1])
+		if err != nil {
+			return nil, nil, err
+		}
+		packageVersion, ok := versions[packages[0]]
+		if !ok {
+			unpackaged = append(unpackaged, distribution)
+			continue
+		}
+
+		var sqlVersion string
+		if _, ok := template.Attributes["sql"]; ok {
+			annotation := template.Annotations["sql"]
+			if annotation == nil || annotation.ExtractVersion == "" {
+				return nil, nil, fmt.Errorf("no extractVersion renovate annotation for the sql version of PostgreSQL %s on %s",
+					template.MajorVersion, distribution)
+			}
+			sqlVersion, err = extractVersion(annotation.ExtractVersion, packageVersion)
+			if err != nil {
+				return nil, nil, err
+			}
+		}
+		additions = append(additions, addition{distribution, packageVersion, sqlVersion})
+	}
+	if len(unpackaged) > 0 {
+		return nil, unpackaged, nil
+	}
+
+	for _, addition := range additions {
+		if err := document.addMajor(addition.distribution, majorVersion, addition.packageVersion, addition.sqlVersion); err != nil {
+			return nil, nil, err
+		}
+		added = append(added, addition.distribution)
+	}
+
+	return added, nil, nil
+}
+
+// distributionReferences returns the declarations of the maintenance module
+// still listing a distribution.
+func distributionReferences(distribution string) []string {
+	var references []string
+	if slices.Contains(SupportedDistributions, distribution) {
+		references = append(references, "SupportedDistributions (dagger/maintenance/image.go)")
+	}
+	if _, ok := pgdgSuffixes[distribution]; ok {
+		references = append(references, "pgdgSuffixes (dagger/maintenance/validate.go)")
+	}
+
+	return references
+}
+
+// formatUnpackaged lists the extensions not packaged yet on some distributions.
+func formatUnpackaged(majorVersion string, unpackaged map[string][]string) string {
+	var extensions []string
+	for _, dir := range slices.Sorted(maps.Keys(unpackaged)) {
+		extensions = append(extensions, fmt.Sprintf("%s (%s)", dir, strings.Join(unpackaged[dir], ", ")))
+	}
+
+	return fmt.Sprintf("not packaged yet for PostgreSQL %s: %s", majorVersion, strings.Join(extensions, ", "))
+}
+
+// formatMajorAddition renders in Markdown the distributions a PG major was
+// added to and the ones skipped, by extension.
+func formatMajorAddition(majorVersion string, added map[string][]string, unpackaged map[string][]string) string {
+	var out strings.Builder
+	if len(added) == 0 {
+		fmt.Fprintf(&out, "PostgreSQL %s wasn't added to any extension.\n", majorVersion)
+	} else {
+		fmt.Fprintf(&out, "PostgreSQL %s added to:\n", majorVersion)
+	}
+	for _, dir := range slices.Sorted(maps.Keys(added)) {
+		fmt.Fprintf(&out, "- %s (%s)\n", dir, strings.Join(added[dir], ", "))
+	}
+	if len(unpackaged) > 0 {
+		fmt.Fprintf(&out, "\nSkipped, not packaged yet for PostgreSQL %s:\n", majorVersion)
+	}
+	for _, dir := range slices.Sorted(maps.Keys(unpackaged)) {
+		fmt.Fprintf(&out, "- %s (%s)\n", dir, strings.Join(unpackaged[dir], ", "))
+	}
+
+	return out.String()
+}
+
+// removeFromExtensions applies a removal to the metadata.hcl file of each
+// extension and deletes the system-libs files of the combinations removed
+// from its build matrix. The removal reports whether it changed the document.
+// It refuses to leave an extension with an empty build matrix.
+func removeFromExtensions(
+	ctx context.Context,
+	source *dagger.Directory,
+	dirs []string,
+	remove func(document *metadataDocument) (bool, error),
+) (*dagger.Directory, error) {
+	for _, dir := range dirs {
+		document, err := readMetadataDocument(ctx, source, dir)
+		if err != nil {
+			return nil, err
+		}
+		changed, err := remove(document)
+		if err != nil {
+			return nil, fmt.Errorf("extension %s: %w", dir, err)
+		}
+		if !changed {
+			continue
+		}
+
+		entries, err := document.versionEntries()
+		if err != nil {
+			return nil, fmt.Errorf("extension %s: %w", dir, err)
+		}
+		if len(entries) == 0 {
+			return nil, fmt.Errorf("extension %s: the removal would leave an empty build matrix", dir)
+		}
+		matrix := &buildMatrix{}
+		for _, entry := range entries {
+			matrix.Combinations = append(matrix.Combinations, buildCombo{
+				Distribution: entry.Distribution,
+				MajorVersion: entry.MajorVersion,
+			})
+		}
+
+		content, err := document.bytes()
+		if err != nil {
+			return nil, fmt.Errorf("extension %s: %w", dir, err)
+		}
+		current, err := source.Glob(ctx, path.Join(dir, systemLibsDir, "*"))
+		if err != nil {
+			return nil, err
+		}
+		source = source.
+			WithNewFile(path.Join(dir, metadataFile), string(content)).
+			WithoutFiles(staleOSLibsFiles(current, matrix))
+	}
+
+	return source, nil
+}
@@ -0,0 +1,379 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+)
+
+// packageAttributeRegex matches the package attribute of a version entry
+var packageAttributeRegex = regexp.MustCompile(`(package\s*=\s*)"[^"]*"`)
+
+// sqlAttributeRegex matches the sql attribute of a version entry
+var sqlAttributeRegex = regexp.MustCompile(`(sql\s*=\s*)"[^"]*"`)
+
+// tokenEntry is an entry of an HCL object, or an attribute of a body,
+// located in a token stream.
+type tokenEntry struct {
+	Key string
+	// Start is the index of the first token of the key
+	Start int
+	// ValueStart is the index of the first token of the value
+	ValueStart int
+	// ValueEnd is the index after the last token of the value
+	ValueEnd int
+}
+
+// isObject reports whether the value of an entry is an object.
+func (e tokenEntry) isObject(tokens hclwrite.Tokens) bool {
+	return tokens[e.ValueStart].Type == hclsyntax.TokenOBrace
+}
+
+// versionEntry is the entry of a distribution and PG major in the versions
+// object of a metadata.hcl file.
+type versionEntry struct {
+	Distribution string
+	MajorVersion string
+	Entry        tokenEntry
+	// Attributes are the package and sql attributes of the entry
+	Attributes map[string]tokenEntry
+	// Annotations are the renovate annotations preceding the attributes
+	Annotations map[string]*renovateAnnotation
+}
+
+// metadataDocument is a metadata.hcl file edited through its token stream,
+// so that comments and formatting are preserved.
+type metadataDocument struct {
+	tokens hclwrite.Tokens
+}
+
+// parseMetadataDocument parses a metadata.hcl file for editing.
+func parseMetadataDocument(src []byte) (*metadataDocument, error) {
+	file, diags := hclwrite.ParseConfig(src, metadataFile, hcl.InitialPos)
+	if diags.HasErrors() {
+		return nil, fmt.Errorf("while parsing %s: %w", metadataFile, diags)
+	}
+
+	return &metadataDocument{tokens: file.BuildTokens(nil)}, nil
+}
+
+// bytes returns the content of the edited file, checking it's still valid HCL.
+func (d *metadataDocument) bytes() ([]byte, error) {
+	content := d.tokens.Bytes()
+	if _, diags := hclwrite.ParseConfig(content, metadataFile, hcl.InitialPos); diags.HasErrors() {
+		return nil, fmt.Errorf("while editing %s: %w", metadataFile, diags)
+	}
+
+	return content, nil
+}
+
+// objectEntries returns the entries of the object whose braces are at the
+// given indices, or of the whole token stream when open is -1.
+func objectEntries(tokens hclwrite.Tokens, open int, close int) []tokenEntry {
+	var entries []tokenEntry
+	for i := open + 1; i < close; {
+		key, valueStart, ok := entryKey(tokens, i, close)
+		if !ok {
+			i = skipValue(tokens, i, close) + 1
+			continue
+		}
+		end := skipValue(tokens, valueStart, close)
+		entries = append(entries, tokenEntry{Key: key, Start: i, ValueStart: valueStart, ValueEnd: end})
+		i = end
+	}
+
+	return entries
+}
+
+// entryKey returns the key of the entry starting at the given index, either an
+// identifier or a quoted string followed by "=", and the index of its value.
+func entryKey(tokens hclwrite.Tokens, i int, close int) (string, int, bool) {
+	switch {
+	case tokens[i].Type == hclsyntax.TokenIdent && i+1 < close && tokens[i+1].Type == hclsyntax.TokenEqual:
+		return string(tokens[i].Bytes), i + 2, true
+	case tokens[i].Type == hclsyntax.TokenOQuote && i+3 < close &&
+		tokens[i+1].Type == hclsyntax.TokenQuotedLit &&
+		tokens[i+2].Type == hclsyntax.TokenCQuote &&
+		tokens[i+3].Type == hclsyntax.TokenEqual:
+		return string(tokens[i+1].Bytes), i + 4, true
+	}
+
+	return "", 0, false
+}
+
+// skipValue returns the index of the newline, comma or comment ending the
+// expression starting at the given index, skipping nested brackets.
+func skipValue(tokens hclwrite.Tokens, i int, close int) int {
+	depth := 0
+	for ; i < close; i++ {
+		switch tokens[i].Type {
+		case hclsyntax.TokenOBrace, hclsyntax.TokenOBrack, hclsyntax.TokenOParen:
+			depth++
+		case hclsyntax.TokenCBrace, hclsyntax.TokenCBrack, hclsyntax.TokenCParen:
+			depth--
+		case hclsyntax.TokenNewline, hclsyntax.TokenComma, hclsyntax.TokenComment:
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+
+	return close
+}
+
+// findEntry returns the entry with the given key.
+func findEntry(entries []tokenEntry, key string) (tokenEntry, bool) {
+	index := slices.IndexFunc(entries, func(entry tokenEntry) bool {
+		return entry.Key == key
+	})
+	if index < 0 {
+		return tokenEntry{}, false
+	}
+
+	return entries[index], true
+}
+
+// objectEntriesOf returns the entries of the object value of an entry.
+func objectEntriesOf(tokens hclwrite.Tokens, entry tokenEntry) []tokenEntry {
+	return objectEntries(tokens, entry.ValueStart, entry.ValueEnd-1)
+}
+
+// metadataEntries returns the entries of the metadata object.
+func (d *metadataDocument) metadataEntries() ([]tokenEntry, error) {
+	metadata, ok := findEntry(objectEntries(d.tokens, -1, len(d.tokens)), "metadata")
+	if !ok || !metadata.isObject(d.tokens) {
+		return nil, fmt.Errorf("no metadata object found in %s", metadataFile)
+	}
+
+	return objectEntriesOf(d.tokens, metadata), nil
+}
+
+// versionsEntry returns the entry of the versions object.
+func (d *metadataDocument) versionsEntry() (tokenEntry, error) {
+	entries, err := d.metadataEntries()
+	if err != nil {
+		return tokenEntry{}, err
+	}
+	versions, ok := findEntry(entries, "versions")
+	if !ok || !versions.isObject(d.tokens) {
+		return tokenEntry{}, fmt.Errorf("no versions object found in %s", metadataFile)
+	}
+
+	return versions, nil
+}
+
+// distributionEntries returns the entries of the distributions in the versions object.
+func (d *metadataDocument) distributionEntries() ([]tokenEntry, error) {
+	versions, err := d.versionsEntry()
+	if err != nil {
+		return nil, err
+	}
+
+	return objectEntriesOf(d.tokens, versions), nil
+}
+
+// versionEntries returns the entries of every distribution and PG major, in
+// the order they appear in the file.
+func (d *metadataDocument) versionEntries() ([]versionEntry, error) {
+	distributions, err := d.distributionEntries()
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []versionEntry
+	for _, distribution := range distributions {
+		if !distribution.isObject(d.tokens) {
+			return nil, fmt.Errorf("distribution %s is not an object", distribution.Key)
+		}
+		for _, major := range objectEntriesOf(d.tokens, distribution) {
+			if !major.isObject(d.tokens) {
+				return nil, fmt.Errorf("PostgreSQL %s on %s is not an object", major.Key, distribution.Key)
+			}
+			entry := versionEntry{
+				Distribution: distribution.Key,
+				MajorVersion: major.Key,
+				Entry:        major,
+				Attributes:   make(map[string]tokenEntry),
+				Annotations:  make(map[string]*renovateAnnotation),
+			}
+			for _, attribute := range objectEntriesOf(d.tokens, major) {
+				entry.Attributes[attribute.Key] = attribute
+				if attribute.Start > 0 && d.tokens[attribute.Start-1].Type == hclsyntax.TokenComment {
+					if annotation, ok := parseRenovateAnnotation(string(d.tokens[attribute.Start-1].Bytes)); ok {
+						entry.Annotations[attribute.Key] = annotation
+					}
+				}
+			}
+			entries = append(entries, entry)
+		}
+	}
+
+	return entries, nil
+}
+
+// attributeValue returns the value of a quoted string attribute of a version entry.
+func (d *metadataDocument) attributeValue(entry versionEntry, name string) (string, bool) {
+	attribute, ok := entry.Attributes[name]
+	if !ok || attribute.ValueEnd-attribute.ValueStart != 3 ||
+		d.tokens[attribute.ValueStart+1].Type != hclsyntax.TokenQuotedLit {
+		return "", false
+	}
+
+	return string(d.tokens[attribute.ValueStart+1].Bytes), true
+}
+
+// setAttribute sets the value of a quoted string attribute of the entry of a
+// distribution and PG major.
+func (d *metadataDocument) setAttribute(distribution string, majorVersion string, name string, value string) error {
+	entries, err := d.versionEntries()
+	if err != nil {
+		return err
+	}
+	index := slices.IndexFunc(entries, func(entry versionEntry) bool {
+		return entry.Distribution == distribution && entry.MajorVersion == majorVersion
+	})
+	if index < 0 {
+		return fmt.Errorf("PostgreSQL %s on %s is not declared", majorVersion, distribution)
+	}
+	if _, ok := d.attributeValue(entries[index], name); !ok {
+		return fmt.Errorf("no %s string attribute for PostgreSQL %s on %s", name, majorVersion, distribution)
+	}
+
+	d.tokens[entries[index].Attributes[name].ValueStart+1].Bytes = []byte(value)
+
+	return nil
+}
+
+// addMajor adds the entry of a PG major to a distribution, after its last
+// PG major, which is used as a template: the renovate annotations and the
+// formatting are kept, the package and sql versions are replaced. The sql
+// attribute is only set when the template has one.
+func (d *metadataDocument) addMajor(distribution string, majorVersion string, packageVersion string, sqlVersion string) error {
+	entries, err := d.versionEntries()
+	if err != nil {
+		return err
+	}
+
+	var template *versionEntry
+	for i := range entries {
+		if entries[i].Distribution != distribution {
+			continue
+		}
+		if entries[i].MajorVersion == majorVersion {
+			return fmt.Errorf("PostgreSQL %s on %s is already declared", majorVersion, distribution)
+		}
+		template = &entries[i]
+	}
+	if template == nil {
+		return fmt.Errorf("no PostgreSQL major declared on %s to use as a template", distribution)
+	}
+
+	snippet := d.tokens[template.Entry.Start:template.Entry.ValueEnd].Bytes()
+	snippet = []byte(strings.Replace(string(snippet),
+		fmt.Sprintf("%q", template.MajorVersion), fmt.Sprintf("%q", majorVersion), 1))
+	snippet = []byte(strings.ReplaceAll(string(snippet),
+		"postgresql-"+template.MajorVersion+"-", "postgresql-"+majorVersion+"-"))
+	snippet = packageAttributeRegex.ReplaceAll(snippet, []byte(fmt.Sprintf("${1}%q", packageVersion)))
+	snippet = sqlAttributeRegex.ReplaceAll(snippet, []byte(fmt.Sprintf("${1}%q", sqlVersion)))
+
+	tokens, err := lexObjectEntry(snippet)
+	if err != nil {
+		return err
+	}
+	insert := append(hclwrite.Tokens{{Type: hclsyntax.TokenNewline, Bytes: []byte("\n")}}, tokens...)
+	d.tokens = slices.Insert(d.tokens, template.Entry.ValueEnd, insert...)
+
+	return nil
+}
+
+// removeEntry removes an entry along with the comments preceding it and the
+// end of its line.
+func (d *metadataDocument) removeEntry(entry tokenEntry) {
+	start := entry.Start
+	for start > 0 && d.tokens[start-1].Type == hclsyntax.TokenComment {
+		start--
+	}
+	end := entry.ValueEnd
+	if end < len(d.tokens) && d.tokens[end].Type == hclsyntax.TokenComma {
+		end++
+	}
+	if end < len(d.tokens) && d.tokens[end].Type == hclsyntax.TokenNewline {
+		end++
+	}
+	d.tokens = slices.Delete(d.tokens, start, end)
+}
+
+// removeDistribution removes a distribution from the versions object.
+func (d *metadataDocument) removeDistribution(distribution string) error {
+	distributions, err := d.distributionEntries()
+	if err != nil {
+		return err
+	}
+	entry, ok := findEntry(distributions, distribution)
+	if !ok {
+		return fmt.Errorf("distribution %s is not declared", distribution)
+	}
+	d.removeEntry(entry)
+
+	return nil
+}
+
+// removeMajor removes a PG major from every distribution, along with the
+// distributions left without any PG major. It returns the distributions the
+// PG major was removed from.
+func (d *metadataDocument) removeMajor(majorVersion string) ([]string, error) {
+	var removed []string
+	for {
+		distributions, err := d.distributionEntries()
+		if err != nil {
+			return nil, err
+		}
+
+		index := slices.IndexFunc(distributions, func(distribution tokenEntry) bool {
+			if !distribution.isObject(d.tokens) {
+				return false
+			}
+			_, ok := findEntry(objectEntriesOf(d.tokens, distribution), majorVersion)
+			return ok
+		})
+		if index < 0 {
+			return removed, nil
+		}
+
+		distribution := distributions[index]
+		majors := objectEntriesOf(d.tokens, distribution)
+		removed = append(removed, distribution.Key)
+		if len(majors) == 1 {
+			d.removeEntry(distribution)
+			continue
+		}
+		entry, _ := findEntry(majors, majorVersion)
+		d.removeEntry(entry)
+	}
+}
+
+// lexObjectEntry returns the tokens of an object entry, keeping its indentation.
+func lexObjectEntry(entry []byte) (hclwrite.Tokens, error) {
+	src := append(append([]byte("entry = {\n"), entry...), "\n}\n"...)
+	file, diags := hclwrite.ParseConfig(src, metadataFile, hcl.InitialPos)
+	if diags.HasErrors() {
+		return nil, fmt.Errorf("while building %s entry: %w", metadataFile, diags)
+	}
+
+	tokens := file.BuildTokens(nil)
+	// Drop the wrapping "entry = {\n" and "\n}\n" tokens
+	start := slices.IndexFunc(tokens, func(token *hclwrite.Token) bool {
+		return token.Type == hclsyntax.TokenNewline
+	}) + 1
+	end := len(tokens) - 1
+	for end > start && tokens[end].Type != hclsyntax.TokenCBrace {
+		end--
+	}
+
+	return slices.Clone(tokens[start : end-1]), nil
+}
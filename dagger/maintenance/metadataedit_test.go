@@ -0,0 +1,262 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+const editableMetadata = `# SPDX-License-Identifier: Apache-2.0
+metadata = {
+  name                     = "pgvector"
+  sql_name                 = "vector"
+  packages                 = ["postgresql-%version%-pgvector"]
+  env                      = {
+    "FOO" = "$${image_root}/share",
+  }
+
+  versions = {
+    bookworm = {
+      "18" = {
+        // renovate: suite=bookworm-pgdg depName=postgresql-18-pgvector
+        package = "0.8.1-2.pgdg12+1"
+        // renovate: suite=bookworm-pgdg depName=postgresql-18-pgvector extractVersion=^(?<version>\d+\.\d+\.\d+)
+        sql     = "0.8.1"
+      }
+    }
+    trixie = {
+      "17" = {
+        // renovate: suite=trixie-pgdg depName=postgresql-17-pgvector
+        package = "0.8.0-1.pgdg13+1"
+      }
+      "18" = {
+        // renovate: suite=trixie-pgdg depName=postgresql-18-pgvector
+        package = "0.8.1-2.pgdg13+1"
+      }
+    }
+  }
+}
+
+target "default" {
+  args = {
+    FOO = "bar"
+  }
+}
+`
+
+func TestMetadataDocumentRoundTrip(t *testing.T) {
+	document, err := parseMetadataDocument([]byte(editableMetadata))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	content, err := document.bytes()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(content) != editableMetadata {
+		t.Errorf("got %q, want the unchanged file", content)
+	}
+}
+
+func TestMetadataDocumentVersionEntries(t *testing.T) {
+	document, err := parseMetadataDocument([]byte(editableMetadata))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	entries, err := document.versionEntries()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var got []string
+	for _, entry := range entries {
+		packageVersion, _ := document.attributeValue(entry, "package")
+		got = append(got, entry.Distribution+"/"+entry.MajorVersion+"="+packageVersion)
+	}
+	want := "bookworm/18=0.8.1-2.pgdg12+1 trixie/17=0.8.0-1.pgdg13+1 trixie/18=0.8.1-2.pgdg13+1"
+	if strings.Join(got, " ") != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	annotation := entries[0].Annotations["sql"]
+	if annotation == nil || annotation.Suite != "bookworm-pgdg" || annotation.ExtractVersion != `^(?<version>\d+\.\d+\.\d+)` {
+		t.Errorf("got %+v, want the renovate annotation of the sql attribute", annotation)
+	}
+	if _, ok := entries[1].Attributes["sql"]; ok {
+		t.Errorf("got a sql attribute for trixie/17, want none")
+	}
+}
+
+func TestMetadataDocumentAddMajor(t *testing.T) {
+	document, err := parseMetadataDocument([]byte(editableMetadata))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := document.addMajor("bookworm", "19", "0.8.2-1.pgdg12+1", "0.8.2"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := document.addMajor("trixie", "19", "0.8.2-1.pgdg13+1", ""); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	content, err := document.bytes()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, want := range []string{
+		`      }
+      "19" = {
+        // renovate: suite=bookworm-pgdg depName=postgresql-19-pgvector
+        package = "0.8.2-1.pgdg12+1"
+        // renovate: suite=bookworm-pgdg depName=postgresql-19-pgvector extractVersion=^(?<version>\d+\.\d+\.\d+)
+        sql     = "0.8.2"
+      }
+    }
+    trixie = {`,
+		`      "19" = {
+        // renovate: suite=trixie-pgdg depName=postgresql-19-pgvector
+        package = "0.8.2-1.pgdg13+1"
+      }
+    }
+  }
+}`,
+	} {
+		if !strings.Contains(string(content), want) {
+			t.Errorf("got %s, want it to contain %s", content, want)
+		}
+	}
+
+	if err := document.addMajor("trixie", "19", "0.8.2-1.pgdg13+1", ""); err == nil {
+		t.Error("expected an error for an already declared PG major")
+	}
+	if err := document.addMajor("bullseye", "19", "0.8.2-1.pgdg11+1", ""); err == nil {
+		t.Error("expected an error for an undeclared distribution")
+	}
+}
+
+func TestMetadataDocumentRemoveDistribution(t *testing.T) {
+	document, err := parseMetadataDocument([]byte(editableMetadata))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := document.removeDistribution("bookworm"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	content, err := document.bytes()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := `  versions = {
+    trixie = {
+      "17" = {`
+	if !strings.Contains(string(content), want) || strings.Contains(string(content), "bookworm") {
+		t.Errorf("got %s, want bookworm removed", content)
+	}
+
+	if err := document.removeDistribution("bookworm"); err == nil {
+		t.Error("expected an error for an undeclared distribution")
+	}
+}
+
+func TestMetadataDocumentRemoveMajor(t *testing.T) {
+	document, err := parseMetadataDocument([]byte(editableMetadata))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	removed, err := document.removeMajor("17")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Join(removed, ",") != "trixie" {
+		t.Errorf("got %v, want [trixie]", removed)
+	}
+	content, err := document.bytes()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := `    trixie = {
+      "18" = {
+        // renovate: suite=trixie-pgdg depName=postgresql-18-pgvector
+        package = "0.8.1-2.pgdg13+1"
+      }
+    }`
+	if !strings.Contains(string(content), want) || strings.Contains(string(content), `"17"`) {
+		t.Errorf("got %s, want PostgreSQL 17 removed", content)
+	}
+
+	// Removing the last PG major of every distribution empties the versions object
+	if _, err := document.removeMajor("18"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	entries, err := document.versionEntries()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("got %d entries, want none", len(entries))
+	}
+	if _, err := document.bytes(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestMetadataDocumentSetAttribute(t *testing.T) {
+	document, err := parseMetadataDocument([]byte(editableMetadata))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := document.setAttribute("bookworm", "18", "package", "0.8.2-1.pgdg12+1"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := document.setAttribute("bookworm", "18", "sql", "0.8.2"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	content, err := document.bytes()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := strings.NewReplacer(
+		`package = "0.8.1-2.pgdg12+1"`, `package = "0.8.2-1.pgdg12+1"`,
+		`sql     = "0.8.1"`, `sql     = "0.8.2"`,
+	).Replace(editableMetadata)
+	if string(content) != want {
+		t.Errorf("got %s, want %s", content, want)
+	}
+
+	if err := document.setAttribute("trixie", "17", "sql", "0.8.0"); err == nil {
+		t.Errorf("expected an error setting a missing attribute")
+	}
+	if err := document.setAttribute("trixie", "16", "package", "0.8.0-1.pgdg13+1"); err == nil {
+		t.Errorf("expected an error setting an undeclared PG major")
+	}
+}
+
+func TestFormatMajorAddition(t *testing.T) {
+	summary := formatMajorAddition("19",
+		map[string][]string{"pgvector": {"bookworm", "trixie"}, "pg-ivm": {"trixie"}},
+		map[string][]string{"postgis": {"trixie"}},
+	)
+	want := "PostgreSQL 19 added to:\n" +
+		"- pg-ivm (trixie)\n" +
+		"- pgvector (bookworm, trixie)\n" +
+		"\nSkipped, not packaged yet for PostgreSQL 19:\n" +
+		"- postgis (trixie)\n"
+	if summary != want {
+		t.Errorf("got %q, want %q", summary, want)
+	}
+
+	if summary := formatMajorAddition("19", nil, nil); summary != "PostgreSQL 19 wasn't added to any extension.\n" {
+		t.Errorf("got %q for no addition", summary)
+	}
+}
+
+func TestDistributionReferences(t *testing.T) {
+	if references := distributionReferences("bookworm"); len(references) != 2 {
+		t.Errorf("got %v, want SupportedDistributions and pgdgSuffixes", references)
+	}
+	if references := distributionReferences("buster"); len(references) != 0 {
+		t.Errorf("got %v, want none", references)
+	}
+}
@@ -0,0 +1,304 @@
+package main
+
+import (
+	"bufio"
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+const (
+	// osLibsLockVersion is the version of the OS libraries lockfile format
+	osLibsLockVersion = 1
+	// osLibsTextPlatform is the platform the OS libraries text file is derived from
+	osLibsTextPlatform = "linux/amd64"
+)
+
+// printURIsRegex matches the packages downloaded by apt-get --print-uris
+// Format: 'url' file-name size [hash]
+var printURIsRegex = regexp.MustCompile(`^'\S+'\s+(\S+\.deb)\s+\d+(?:\s+\S+)?$`)
+
+// aptProgressRegex matches the progress lines apt-get may print even when quiet,
+// such as "Reading package lists..." or "Building dependency tree... Done"
+var aptProgressRegex = regexp.MustCompile(`^[A-Z][a-z ]+\.\.\.(?: Done)?$`)
+
+// aptPackageNotFoundRegex matches the error printed by apt-get for an unknown package
+var aptPackageNotFoundRegex = regexp.MustCompile(`^E: Unable to locate package (\S+)`)
+
+// aptVersionNotFoundRegex matches the error printed by apt-get for an unknown package version
+var aptVersionNotFoundRegex = regexp.MustCompile(`^E: Version '([^']+)' for '([^']+)' was not found`)
+
+var (
+	// errOSLibsPackageNotFound is returned when a package is unknown to apt
+	errOSLibsPackageNotFound = errors.New("package not found")
+	// errOSLibsVersionNotFound is returned when the pinned version of a package is unknown to apt
+	errOSLibsVersionNotFound = errors.New("package version not found")
+)
+
+// osLibsUnpackaged marks the combinations whose packages aren't in the pgdg
+// repository yet
+const osLibsUnpackaged = "unpackaged"
+
+// osLibsLock is the lockfile of the OS libraries an extension depends on,
+// for a distribution and PG major.
+type osLibsLock struct {
+	Version      int    `json:"version"`
+	Extension    string `json:"extension"`
+	Distribution string `json:"distribution"`
+	PgMajor      string `json:"pgMajor"`
+	// Platforms maps each platform to its packages, sorted by name
+	Platforms map[string][]osLibsLockPackage `json:"platforms"`
+}
+
+// osLibsLockPackage is a package recorded in the OS libraries lockfile.
+type osLibsLockPackage struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	Arch    string `json:"arch"`
+	SHA256  string `json:"sha256"`
+	Source  string `json:"source"`
+	// MD5 is only kept to derive the OS libraries text file
+	MD5 string `json:"md5,omitempty"`
+}
+
+// osLibsLockFileName returns the name of the lockfile for a distribution and PG major.
+func osLibsLockFileName(majorVersion string, distribution string) string {
+	return fmt.Sprintf("%s-%s-os-libs.lock.json", majorVersion, distribution)
+}
+
+// osLibsTextFileName returns the name of the text file for a distribution and PG major.
+func osLibsTextFileName(majorVersion string, distribution string) string {
+	return fmt.Sprintf("%s-%s-os-libs.txt", majorVersion, distribution)
+}
+
+// staleOSLibsFiles returns the OS libraries files, lockfiles and text files,
+// whose distribution and PG major are no longer part of the build matrix.
+// Other files are left alone.
+func staleOSLibsFiles(files []string, matrix *buildMatrix) []string {
+	var stale []string
+	for _, file := range files {
+		matches := osLibsFileRegex.FindStringSubmatch(path.Base(file))
+		if matches == nil {
+			matches = osLibsLockFileRegex.FindStringSubmatch(path.Base(file))
+		}
+		if matches == nil {
+			continue
+		}
+		if !slices.Contains(matrix.Combinations, buildCombo{Distribution: matches[2], MajorVersion: matches[1]}) {
+			stale = append(stale, file)
+		}
+	}
+	slices.Sort(stale)
+
+	return stale
+}
+
+// parseOSLibsLock decodes an OS libraries lockfile.
+func parseOSLibsLock(data []byte) (*osLibsLock, error) {
+	var lock osLibsLock
+	if err := json.Unmarshal(data, &lock); err != nil {
+		return nil, fmt.Errorf("while decoding OS libs lockfile: %w", err)
+	}
+	if lock.Version != osLibsLockVersion {
+		return nil, fmt.Errorf("unsupported OS libs lockfile version %d", lock.Version)
+	}
+
+	return &lock, nil
+}
+
+// marshal encodes an OS libraries lockfile, with the packages of each platform
+// sorted by name, so that the output is stable across runs. The lock itself
+// keeps the order of its packages.
+func (l *osLibsLock) marshal() ([]byte, error) {
+	sorted := *l
+	sorted.Platforms = make(map[string][]osLibsLockPackage, len(l.Platforms))
+	for platform, packages := range l.Platforms {
+		sorted.Platforms[platform] = slices.SortedFunc(slices.Values(packages), func(a, b osLibsLockPackage) int {
+			return cmp.Or(cmp.Compare(a.Name, b.Name), cmp.Compare(a.Arch, b.Arch))
+		})
+	}
+
+	data, err := json.MarshalIndent(&sorted, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	return append(data, '\n'), nil
+}
+
+// osPackages returns the packages of a platform, in the form used by the scan.
+func (l *osLibsLock) osPackages(platform string) []osPackage {
+	packages := make([]osPackage, 0, len(l.Platforms[platform]))
+	for _, pkg := range l.Platforms[platform] {
+		packages = append(packages, osPackage{
+			Name:    pkg.Name,
+			Version: pkg.Version,
+			Arch:    pkg.Arch,
+			Source:  pkg.Source,
+		})
+	}
+
+	return packages
+}
+
+// sortedPlatforms returns the platforms of the lockfile, sorted.
+func (l *osLibsLock) sortedPlatforms() []string {
+	return slices.Sorted(maps.Keys(l.Platforms))
+}
+
+// parsePrintURIs classifies the result of apt-get install --print-uris and
+// returns the packages it would download. An empty list is a success: the
+// dependencies are all shipped by the base image. When apt-get only reports
+// missing packages or versions, the error wraps errOSLibsPackageNotFound or
+// errOSLibsVersionNotFound, see osLibsJob.settle. Any other apt-get failure
+// and an unparsable output are plain errors.
+func parsePrintURIs(stdout string, stderr string, exitCode int) ([]osPackage, error) {
+	var notFound []error
+	var failures []string
+	for _, line := range strings.Split(stderr, "\n") {
+		line = strings.TrimSpace(line)
+		if matches := aptPackageNotFoundRegex.FindStringSubmatch(line); matches != nil {
+			notFound = append(notFound, fmt.Errorf("%w: %s", errOSLibsPackageNotFound, matches[1]))
+		} else if matches := aptVersionNotFoundRegex.FindStringSubmatch(line); matches != nil {
+			notFound = append(notFound, fmt.Errorf("%w: %s=%s", errOSLibsVersionNotFound, matches[2], matches[1]))
+		}
+		if strings.HasPrefix(line, "E: ") {
+			failures = append(failures, strings.TrimPrefix(line, "E: "))
+		}
+	}
+	// A missing package only explains the failure when apt-get reports nothing else
+	if len(notFound) > 0 && len(notFound) == len(failures) {
+		return nil, errors.Join(notFound...)
+	}
+	if len(failures) > 0 {
+		return nil, fmt.Errorf("apt-get failed: %s", strings.Join(failures, "; "))
+	}
+	if exitCode != 0 {
+		return nil, fmt.Errorf("apt-get failed: exit code %d", exitCode)
+	}
+
+	var packages []osPackage
+	for _, line := range strings.Split(stdout, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || aptProgressRegex.MatchString(line) {
+			continue
+		}
+		matches := printURIsRegex.FindStringSubmatch(line)
+		if matches == nil {
+			return nil, fmt.Errorf("unparsable apt-get output: %q", line)
+		}
+		pkg, err := parseDebFileName(matches[1])
+		if err != nil {
+			return nil, fmt.Errorf("unparsable apt-get output: %w", err)
+		}
+		packages = append(packages, *pkg)
+	}
+
+	return packages, nil
+}
+
+// parseAptCacheShow parses the stanzas printed by apt-cache show into lockfile
+// packages. A package without a Source field is its own source package.
+func parseAptCacheShow(output string) ([]osLibsLockPackage, error) {
+	var packages []osLibsLockPackage
+	var current map[string]string
+
+	flush := func() error {
+		if current == nil {
+			return nil
+		}
+		pkg := osLibsLockPackage{
+			Name:    current["Package"],
+			Version: current["Version"],
+			Arch:    current["Architecture"],
+			SHA256:  current["SHA256"],
+			MD5:     current["MD5sum"],
+		}
+		if pkg.Name == "" || pkg.Version == "" {
+			return fmt.Errorf("apt-cache show stanza without package name or version")
+		}
+		pkg.Source, _, _ = strings.Cut(current["Source"], " ")
+		if pkg.Source == "" {
+			pkg.Source = pkg.Name
+		}
+		packages = append(packages, pkg)
+		current = nil
+		return nil
+	}
+
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.TrimSpace(line) == "" {
+			if err := flush(); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		// Continuation lines belong to multi-line fields, such as Description
+		if line[0] == ' ' || line[0] == '\t' {
+			continue
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		if current == nil {
+			current = make(map[string]string)
+		}
+		current[key] = strings.TrimSpace(value)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+
+	return packages, nil
+}
+
+// selectLockPackages returns, for each requested package, the matching stanza
+// printed by apt-cache show, which may list several versions or architectures.
+func selectLockPackages(requested []osPackage, stanzas []osLibsLockPackage) ([]osLibsLockPackage, error) {
+	packages := make([]osLibsLockPackage, 0, len(requested))
+	for _, pkg := range requested {
+		index := slices.IndexFunc(stanzas, func(stanza osLibsLockPackage) bool {
+			return stanza.Name == pkg.Name && stanza.Version == pkg.Version &&
+				(stanza.Arch == pkg.Arch || stanza.Arch == "all")
+		})
+		if index < 0 {
+			return nil, fmt.Errorf("no apt-cache entry found for %s %s (%s)", pkg.Name, pkg.Version, pkg.Arch)
+		}
+		packages = append(packages, stanzas[index])
+	}
+
+	return packages, nil
+}
+
+// formatOSLibsText derives the OS libraries text file from the packages of a
+// lockfile platform: the library packages, with the file name printed by
+// apt-get --print-uris and their MD5 checksum, in the order apt-get printed
+// them.
+func formatOSLibsText(packages []osLibsLockPackage) string {
+	var out strings.Builder
+	for _, pkg := range packages {
+		if !strings.HasPrefix(pkg.Name, "lib") {
+			continue
+		}
+		fmt.Fprintf(&out, "%s_%s_%s.deb", pkg.Name, strings.ReplaceAll(pkg.Version, ":", "%3a"), pkg.Arch)
+		if pkg.MD5 != "" {
+			fmt.Fprintf(&out, " MD5Sum:%s", pkg.MD5)
+		}
+		fmt.Fprintln(&out)
+	}
+
+	return out.String()
+}
@@ -0,0 +1,274 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"slices"
+	"testing"
+)
+
+func TestParsePrintURIs(t *testing.T) {
+	tests := []struct {
+		name     string
+		stdout   string
+		stderr   string
+		exitCode int
+		want     []osPackage
+		wantErr  error
+		// wantFail is set when a plain error is expected
+		wantFail bool
+	}{
+		{
+			name:   "standard line with MD5Sum",
+			stdout: "'http://deb.debian.org/debian/pool/main/liba/libaec/libaec0_1.0.6-1+b1_amd64.deb' libaec0_1.0.6-1+b1_amd64.deb 22052 MD5Sum:42611bf8032dad2d74c26d8dc084d322",
+			want:   []osPackage{{Name: "libaec0", Version: "1.0.6-1+b1", Arch: "amd64"}},
+		},
+		{
+			name:   "line without MD5Sum",
+			stdout: "'http://deb.debian.org/debian/pool/main/libn/libnss3/libnss3_3.87.1-1+deb12u1_amd64.deb' libnss3_3.87.1-1+deb12u1_amd64.deb 1378920",
+			want:   []osPackage{{Name: "libnss3", Version: "3.87.1-1+deb12u1", Arch: "amd64"}},
+		},
+		{
+			name:   "epoch in version (URL-encoded colon)",
+			stdout: "'http://deb.debian.org/debian/pool/main/liba/libarmadillo/libarmadillo11_1%3a11.4.2+dfsg-1_amd64.deb' libarmadillo11_1%3a11.4.2+dfsg-1_amd64.deb 11340 MD5Sum:0ec736fe1888c654c32c3812add9d61d",
+			want:   []osPackage{{Name: "libarmadillo11", Version: "1:11.4.2+dfsg-1", Arch: "amd64"}},
+		},
+		{
+			name:   "non-lib package is included",
+			stdout: "'http://deb.debian.org/debian/pool/main/p/proj/proj-data_9.1.1-1_all.deb' proj-data_9.1.1-1_all.deb 7891012 MD5Sum:deadbeef",
+			want:   []osPackage{{Name: "proj-data", Version: "9.1.1-1", Arch: "all"}},
+		},
+		{
+			name: "progress lines from apt-get",
+			stdout: `Reading package lists...
+Building dependency tree... Done
+'http://example.com/libfoo_1.0_amd64.deb' libfoo_1.0_amd64.deb 4096
+`,
+			want: []osPackage{{Name: "libfoo", Version: "1.0", Arch: "amd64"}},
+		},
+		{
+			name: "no dependencies to download",
+		},
+		{
+			name:     "unknown package",
+			stderr:   "E: Unable to locate package postgresql-19-foo\n",
+			exitCode: 100,
+			wantErr:  errOSLibsPackageNotFound,
+		},
+		{
+			name:     "unknown package version",
+			stderr:   "E: Version '1.0-1' for 'postgresql-18-foo' was not found\n",
+			exitCode: 100,
+			wantErr:  errOSLibsVersionNotFound,
+		},
+		{
+			name:     "other apt-get error",
+			stderr:   "E: Could not get lock /var/lib/dpkg/lock-frontend\n",
+			exitCode: 100,
+			wantFail: true,
+		},
+		{
+			name:     "failure without error line",
+			exitCode: 1,
+			wantFail: true,
+		},
+		{
+			name:     "unknown package along with another error",
+			stderr:   "E: Unable to locate package postgresql-19-foo\nE: Could not get lock /var/lib/dpkg/lock-frontend\n",
+			exitCode: 100,
+			wantFail: true,
+		},
+		{
+			name:     "non-deb file",
+			stdout:   "'http://example.com/libfoo_1.0.tar.gz' libfoo_1.0.tar.gz 4096 MD5Sum:abc123",
+			wantFail: true,
+		},
+		{
+			name:     "unexpected line",
+			stdout:   "The following NEW packages will be installed:",
+			wantFail: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parsePrintURIs(tt.stdout, tt.stderr, tt.exitCode)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("got error %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if tt.wantFail {
+				if err == nil || errors.Is(err, errOSLibsPackageNotFound) || errors.Is(err, errOSLibsVersionNotFound) {
+					t.Fatalf("got error %v, want a failure", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseAptCacheShow(t *testing.T) {
+	input := `Package: libgeos-c1t64
+Source: geos (3.13.0-1)
+Version: 3.13.0-1
+Architecture: amd64
+Description: Geometry engine for GIS - C API
+ GEOS provides a spatial object model.
+MD5sum: 0123456789abcdef0123456789abcdef
+SHA256: aaaa
+
+Package: proj-data
+Version: 9.5.1-1
+Architecture: all
+MD5sum: fedcba9876543210fedcba9876543210
+SHA256: bbbb
+`
+	want := []osLibsLockPackage{
+		{
+			Name: "libgeos-c1t64", Version: "3.13.0-1", Arch: "amd64", SHA256: "aaaa", Source: "geos",
+			MD5: "0123456789abcdef0123456789abcdef",
+		},
+		{
+			Name: "proj-data", Version: "9.5.1-1", Arch: "all", SHA256: "bbbb", Source: "proj-data",
+			MD5: "fedcba9876543210fedcba9876543210",
+		},
+	}
+
+	got, err := parseAptCacheShow(input)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	if _, err := parseAptCacheShow("Architecture: amd64\n"); err == nil {
+		t.Error("expected an error for a stanza without package name")
+	}
+}
+
+func TestSelectLockPackages(t *testing.T) {
+	stanzas := []osLibsLockPackage{
+		{Name: "libfoo1", Version: "1.1", Arch: "amd64"},
+		{Name: "libfoo1", Version: "1.0", Arch: "amd64", SHA256: "want"},
+		{Name: "foo-data", Version: "1.0", Arch: "all"},
+	}
+	requested := []osPackage{
+		{Name: "libfoo1", Version: "1.0", Arch: "amd64"},
+		{Name: "foo-data", Version: "1.0", Arch: "all"},
+	}
+
+	got, err := selectLockPackages(requested, stanzas)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []osLibsLockPackage{stanzas[1], stanzas[2]}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	if _, err := selectLockPackages([]osPackage{{Name: "libbar1", Version: "1.0", Arch: "amd64"}}, stanzas); err == nil {
+		t.Error("expected an error for a package missing from apt-cache")
+	}
+}
+
+func TestFormatOSLibsText(t *testing.T) {
+	// The packages keep the order printed by apt-get
+	packages := []osLibsLockPackage{
+		{Name: "libnss3", Version: "3.87.1-1", Arch: "amd64"},
+		{Name: "proj-data", Version: "9.1.1-1", Arch: "all", MD5: "deadbeef"},
+		{Name: "libarmadillo11", Version: "1:11.4.2+dfsg-1", Arch: "amd64", MD5: "0ec736fe1888c654c32c3812add9d61d"},
+	}
+	want := "libnss3_3.87.1-1_amd64.deb\n" +
+		"libarmadillo11_1%3a11.4.2+dfsg-1_amd64.deb MD5Sum:0ec736fe1888c654c32c3812add9d61d\n"
+
+	if got := formatOSLibsText(packages); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	// The derived text file must remain readable by the scan
+	parsed, err := parseOSLibs(want)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(parsed) != 2 || parsed[1].Version != "1:11.4.2+dfsg-1" {
+		t.Errorf("got %v, want the two library packages", parsed)
+	}
+}
+
+func TestOSLibsLockRoundTrip(t *testing.T) {
+	lock := &osLibsLock{
+		Version:      osLibsLockVersion,
+		Extension:    "postgis",
+		Distribution: "trixie",
+		PgMajor:      "18",
+		Platforms: map[string][]osLibsLockPackage{
+			"linux/arm64": {
+				{Name: "libproj25", Version: "9.6.0-1", Arch: "arm64", SHA256: "cccc", Source: "proj"},
+			},
+			"linux/amd64": {
+				{Name: "libproj25", Version: "9.6.0-1", Arch: "amd64", SHA256: "bbbb", Source: "proj"},
+				{Name: "libgeos3.13.1", Version: "3.13.1-1", Arch: "amd64", SHA256: "aaaa", Source: "geos"},
+			},
+		},
+	}
+
+	data, err := lock.marshal()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got, err := parseOSLibsLock(data)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got.Platforms["linux/amd64"][0].Name != "libgeos3.13.1" {
+		t.Errorf("packages are not sorted by name: %v", got.Platforms["linux/amd64"])
+	}
+	// The lock keeps the apt order the text file is derived in
+	if lock.Platforms["linux/amd64"][0].Name != "libproj25" {
+		t.Errorf("marshal reordered the packages of the lock: %v", lock.Platforms["linux/amd64"])
+	}
+	slices.Reverse(got.Platforms["linux/amd64"])
+	if !reflect.DeepEqual(got, lock) {
+		t.Errorf("got %v, want %v", got, lock)
+	}
+	if platforms := got.sortedPlatforms(); !reflect.DeepEqual(platforms, []string{"linux/amd64", "linux/arm64"}) {
+		t.Errorf("got %v, want sorted platforms", platforms)
+	}
+	if packages := got.osPackages("linux/arm64"); len(packages) != 1 || packages[0].Source != "proj" {
+		t.Errorf("got %v, want the arm64 packages with their source", packages)
+	}
+
+	if _, err := parseOSLibsLock([]byte(`{"version": 2}`)); err == nil {
+		t.Error("expected an error for an unsupported version")
+	}
+}
+
+func TestStaleOSLibsFiles(t *testing.T) {
+	matrix := &buildMatrix{Combinations: []buildCombo{
+		{Distribution: "trixie", MajorVersion: "18"},
+	}}
+	files := []string{
+		"postgis/system-libs/18-trixie-os-libs.txt",
+		"postgis/system-libs/18-trixie-os-libs.lock.json",
+		"postgis/system-libs/18-bookworm-os-libs.txt",
+		"postgis/system-libs/17-trixie-os-libs.lock.json",
+		"postgis/system-libs/README.md",
+	}
+	want := []string{
+		"postgis/system-libs/17-trixie-os-libs.lock.json",
+		"postgis/system-libs/18-bookworm-os-libs.txt",
+	}
+
+	if got := staleOSLibsFiles(files, matrix); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
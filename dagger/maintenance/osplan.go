@@ -0,0 +1,295 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"dagger/maintenance/internal/dagger"
+)
+
+// osLibsPlan is the list of changes UpdateOSLibs would apply to the OS
+// libraries files, for each extension and combination.
+type osLibsPlan struct {
+	Combinations []osLibsComboPlan `json:"combinations"`
+	// Stale are the files of the combinations dropped from the build matrix
+	Stale []string `json:"stale,omitempty"`
+}
+
+// osLibsComboPlan holds the changes of the OS libraries of an extension for
+// a distribution and PG major.
+type osLibsComboPlan struct {
+	Extension    string `json:"extension"`
+	Distribution string `json:"distribution"`
+	PgMajor      string `json:"pgMajor"`
+	// Origin is the file the resolved libraries are compared to, empty when none exists
+	Origin    string               `json:"origin,omitempty"`
+	Platforms []osLibsPlatformDiff `json:"platforms"`
+	// Skipped is osLibsUnpackaged when the packages aren't in the pgdg
+	// repository yet, the current files being kept
+	Skipped string `json:"skipped,omitempty"`
+	// Reason is the apt-get error of a skipped combination
+	Reason string `json:"reason,omitempty"`
+}
+
+// osLibsPlatformDiff holds the package changes of a single platform.
+type osLibsPlatformDiff struct {
+	Platform   string                `json:"platform"`
+	Added      []osLibsLockPackage   `json:"added,omitempty"`
+	Removed    []osLibsLockPackage   `json:"removed,omitempty"`
+	Upgraded   []osLibsVersionChange `json:"upgraded,omitempty"`
+	Downgraded []osLibsVersionChange `json:"downgraded,omitempty"`
+}
+
+// osLibsVersionChange is a package whose version changed.
+type osLibsVersionChange struct {
+	Name        string `json:"name"`
+	Arch        string `json:"arch"`
+	FromVersion string `json:"fromVersion"`
+	ToVersion   string `json:"toVersion"`
+}
+
+// empty reports whether a platform has no package changes.
+func (d osLibsPlatformDiff) empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Upgraded) == 0 && len(d.Downgraded) == 0
+}
+
+// changed reports whether the OS libraries of a combination have changes.
+func (p osLibsComboPlan) changed() bool {
+	return slices.ContainsFunc(p.Platforms, func(d osLibsPlatformDiff) bool {
+		return !d.empty()
+	})
+}
+
+// readCurrentOSLibs reads the OS libraries recorded for a distribution and PG
+// major in the system-libs directory of an extension. When only the text file
+// exists, its library packages are returned as the ones of osLibsTextPlatform.
+// It returns a nil lock when no file exists.
+func readCurrentOSLibs(
+	ctx context.Context,
+	systemLibs *dagger.Directory,
+	majorVersion string,
+	distribution string,
+) (*osLibsLock, string, error) {
+	lockFile := osLibsLockFileName(majorVersion, distribution)
+	exists, err := systemLibs.Exists(ctx, lockFile)
+	if err != nil {
+		return nil, "", err
+	}
+	if exists {
+		content, err := systemLibs.File(lockFile).Contents(ctx)
+		if err != nil {
+			return nil, "", err
+		}
+		lock, err := parseOSLibsLock([]byte(content))
+		if err != nil {
+			return nil, "", fmt.Errorf("while parsing %s: %w", lockFile, err)
+		}
+		return lock, lockFile, nil
+	}
+
+	textFile := osLibsTextFileName(majorVersion, distribution)
+	exists, err = systemLibs.Exists(ctx, textFile)
+	if err != nil || !exists {
+		return nil, "", err
+	}
+	content, err := systemLibs.File(textFile).Contents(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+	packages, err := parseOSLibs(content)
+	if err != nil {
+		return nil, "", fmt.Errorf("while parsing %s: %w", textFile, err)
+	}
+
+	lockPackages := make([]osLibsLockPackage, 0, len(packages))
+	for _, pkg := range packages {
+		lockPackages = append(lockPackages, osLibsLockPackage{Name: pkg.Name, Version: pkg.Version, Arch: pkg.Arch})
+	}
+
+	return &osLibsLock{
+		Version:      osLibsLockVersion,
+		Distribution: distribution,
+		PgMajor:      majorVersion,
+		Platforms:    map[string][]osLibsLockPackage{osLibsTextPlatform: lockPackages},
+	}, textFile, nil
+}
+
+// planOSLibs compares the resolved OS libraries of a combination with the
+// current ones. When the current ones come from a text file, which only
+// records the library packages of osLibsTextPlatform, the comparison is
+// restricted to them.
+func planOSLibs(current *osLibsLock, origin string, resolved *osLibsLock) osLibsComboPlan {
+	plan := osLibsComboPlan{
+		Extension:    resolved.Extension,
+		Distribution: resolved.Distribution,
+		PgMajor:      resolved.PgMajor,
+		Origin:       origin,
+	}
+
+	textOnly := strings.HasSuffix(origin, ".txt")
+	platforms := resolved.sortedPlatforms()
+	if textOnly {
+		platforms = []string{osLibsTextPlatform}
+	}
+
+	for _, platform := range platforms {
+		var from []osLibsLockPackage
+		if current != nil {
+			from = current.Platforms[platform]
+		}
+		to := resolved.Platforms[platform]
+		if textOnly {
+			to = slices.DeleteFunc(slices.Clone(to), func(pkg osLibsLockPackage) bool {
+				return !strings.HasPrefix(pkg.Name, "lib")
+			})
+		}
+		plan.Platforms = append(plan.Platforms, diffOSLibs(platform, from, to))
+	}
+
+	return plan
+}
+
+// diffOSLibs compares the packages of a platform. Packages are identified by
+// name and architecture, and the result is sorted by name.
+func diffOSLibs(platform string, from, to []osLibsLockPackage) osLibsPlatformDiff {
+	diff := osLibsPlatformDiff{Platform: platform}
+	key := func(pkg osLibsLockPackage) string {
+		return pkg.Name + ":" + pkg.Arch
+	}
+
+	fromPackages := make(map[string]osLibsLockPackage, len(from))
+	for _, pkg := range from {
+		fromPackages[key(pkg)] = pkg
+	}
+	toPackages := make(map[string]osLibsLockPackage, len(to))
+	for _, pkg := range to {
+		toPackages[key(pkg)] = pkg
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(toPackages)) {
+		pkg := toPackages[name]
+		fromPkg, ok := fromPackages[name]
+		if !ok {
+			diff.Added = append(diff.Added, pkg)
+			continue
+		}
+
+		change := osLibsVersionChange{
+			Name:        pkg.Name,
+			Arch:        pkg.Arch,
+			FromVersion: fromPkg.Version,
+			ToVersion:   pkg.Version,
+		}
+		switch c := compareDebianVersions(fromPkg.Version, pkg.Version); {
+		case c < 0:
+			diff.Upgraded = append(diff.Upgraded, change)
+		case c > 0:
+			diff.Downgraded = append(diff.Downgraded, change)
+		}
+	}
+	for _, name := range slices.Sorted(maps.Keys(fromPackages)) {
+		if _, ok := toPackages[name]; !ok {
+			diff.Removed = append(diff.Removed, fromPackages[name])
+		}
+	}
+
+	return diff
+}
+
+// renderOSLibsPlan renders the OS libraries plan in the given format, either
+// "markdown" or "json".
+func renderOSLibsPlan(plan *osLibsPlan, format string) (string, error) {
+	switch format {
+	case "json":
+		data, err := json.MarshalIndent(plan, "", "  ")
+		if err != nil {
+			return "", err
+		}
+		return string(data) + "\n", nil
+	case "markdown":
+		return formatOSLibsPlan(plan), nil
+	}
+
+	return "", fmt.Errorf("unsupported format %q, must be either markdown or json", format)
+}
+
+// formatOSLibsPlan renders the OS libraries plan in Markdown, only listing the
+// combinations with changes or skipped, suitable for the body of the update
+// pull request.
+func formatOSLibsPlan(plan *osLibsPlan) string {
+	compareCombos := func(a, b osLibsComboPlan) int {
+		return cmp.Or(
+			cmp.Compare(a.Extension, b.Extension),
+			cmp.Compare(a.Distribution, b.Distribution),
+			cmp.Compare(a.PgMajor, b.PgMajor),
+		)
+	}
+	combinations := slices.DeleteFunc(slices.Clone(plan.Combinations), func(combo osLibsComboPlan) bool {
+		return combo.Skipped != "" || !combo.changed()
+	})
+	slices.SortFunc(combinations, compareCombos)
+	skipped := slices.DeleteFunc(slices.Clone(plan.Combinations), func(combo osLibsComboPlan) bool {
+		return combo.Skipped == ""
+	})
+	slices.SortFunc(skipped, compareCombos)
+	if len(combinations) == 0 && len(skipped) == 0 && len(plan.Stale) == 0 {
+		return "No OS libraries changes.\n"
+	}
+
+	var out strings.Builder
+	if len(plan.Stale) > 0 {
+		fmt.Fprintf(&out, "## Stale files\n\n")
+		fmt.Fprintf(&out, "The following files belong to combinations no longer in the build matrix and are removed:\n\n")
+		for _, file := range plan.Stale {
+			fmt.Fprintf(&out, "- `%s`\n", file)
+		}
+	}
+	if len(skipped) > 0 {
+		if len(plan.Stale) > 0 {
+			fmt.Fprintln(&out)
+		}
+		fmt.Fprintf(&out, "## Skipped combinations\n\n")
+		fmt.Fprintf(&out, "The packages of the following combinations aren't available yet, their files are kept:\n\n")
+		for _, combo := range skipped {
+			fmt.Fprintf(&out, "- %s (PostgreSQL %s on %s): %s, %s\n",
+				combo.Extension, combo.PgMajor, combo.Distribution, combo.Skipped, combo.Reason)
+		}
+	}
+	for i, combo := range combinations {
+		if i > 0 || len(plan.Stale) > 0 || len(skipped) > 0 {
+			fmt.Fprintln(&out)
+		}
+		fmt.Fprintf(&out, "## %s (PostgreSQL %s on %s)\n", combo.Extension, combo.PgMajor, combo.Distribution)
+		if combo.Origin == "" {
+			fmt.Fprintf(&out, "\nNo OS libraries recorded yet.\n")
+		}
+
+		for _, platform := range combo.Platforms {
+			if platform.empty() {
+				continue
+			}
+			fmt.Fprintf(&out, "\n### %s\n\n", platform.Platform)
+			fmt.Fprintf(&out, "| Change | Package | From | To |\n")
+			fmt.Fprintf(&out, "|---|---|---|---|\n")
+			for _, pkg := range platform.Added {
+				fmt.Fprintf(&out, "| Added | `%s` | | %s |\n", pkg.Name, pkg.Version)
+			}
+			for _, pkg := range platform.Removed {
+				fmt.Fprintf(&out, "| Removed | `%s` | %s | |\n", pkg.Name, pkg.Version)
+			}
+			for _, change := range platform.Upgraded {
+				fmt.Fprintf(&out, "| Upgraded | `%s` | %s | %s |\n", change.Name, change.FromVersion, change.ToVersion)
+			}
+			for _, change := range platform.Downgraded {
+				fmt.Fprintf(&out, "| Downgraded | `%s` | %s | %s |\n", change.Name, change.FromVersion, change.ToVersion)
+			}
+		}
+	}
+
+	return out.String()
+}
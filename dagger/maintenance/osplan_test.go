@@ -0,0 +1,146 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDiffOSLibs(t *testing.T) {
+	from := []osLibsLockPackage{
+		{Name: "libgeos3.13.0", Version: "3.13.0-1", Arch: "amd64"},
+		{Name: "libproj25", Version: "9.6.0-1", Arch: "amd64"},
+		{Name: "libsqlite3-0", Version: "3.46.1-2", Arch: "amd64"},
+		{Name: "libxml2", Version: "2.12.7+dfsg-3", Arch: "amd64"},
+	}
+	to := []osLibsLockPackage{
+		{Name: "libgeos3.13.1", Version: "3.13.1-1", Arch: "amd64"},
+		{Name: "libproj25", Version: "9.6.0-1", Arch: "amd64"},
+		{Name: "libsqlite3-0", Version: "3.46.1-3", Arch: "amd64"},
+		{Name: "libxml2", Version: "2.12.7+dfsg-2", Arch: "amd64"},
+	}
+
+	got := diffOSLibs("linux/amd64", from, to)
+	want := osLibsPlatformDiff{
+		Platform: "linux/amd64",
+		Added:    []osLibsLockPackage{to[0]},
+		Removed:  []osLibsLockPackage{from[0]},
+		Upgraded: []osLibsVersionChange{
+			{Name: "libsqlite3-0", Arch: "amd64", FromVersion: "3.46.1-2", ToVersion: "3.46.1-3"},
+		},
+		Downgraded: []osLibsVersionChange{
+			{Name: "libxml2", Arch: "amd64", FromVersion: "2.12.7+dfsg-3", ToVersion: "2.12.7+dfsg-2"},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+
+	if diff := diffOSLibs("linux/amd64", from, from); !diff.empty() {
+		t.Errorf("got %+v, want no changes", diff)
+	}
+}
+
+func TestPlanOSLibs(t *testing.T) {
+	resolved := &osLibsLock{
+		Version:      osLibsLockVersion,
+		Extension:    "postgis",
+		Distribution: "trixie",
+		PgMajor:      "18",
+		Platforms: map[string][]osLibsLockPackage{
+			"linux/amd64": {
+				{Name: "libproj25", Version: "9.6.0-1", Arch: "amd64"},
+				{Name: "proj-data", Version: "9.6.0-1", Arch: "all"},
+			},
+			"linux/arm64": {
+				{Name: "libproj25", Version: "9.6.0-1", Arch: "arm64"},
+			},
+		},
+	}
+
+	t.Run("compared to a text file", func(t *testing.T) {
+		current := &osLibsLock{
+			Platforms: map[string][]osLibsLockPackage{
+				osLibsTextPlatform: {{Name: "libproj25", Version: "9.6.0-1", Arch: "amd64"}},
+			},
+		}
+		plan := planOSLibs(current, "postgis/system-libs/18-trixie-os-libs.txt", resolved)
+		if plan.changed() {
+			t.Errorf("got %+v, want no changes", plan.Platforms)
+		}
+		if len(plan.Platforms) != 1 || plan.Platforms[0].Platform != osLibsTextPlatform {
+			t.Errorf("got %+v, want only %s", plan.Platforms, osLibsTextPlatform)
+		}
+	})
+
+	t.Run("without current OS libraries", func(t *testing.T) {
+		plan := planOSLibs(nil, "", resolved)
+		if len(plan.Platforms) != 2 {
+			t.Fatalf("got %d platforms, want 2", len(plan.Platforms))
+		}
+		if len(plan.Platforms[0].Added) != 2 || len(plan.Platforms[1].Added) != 1 {
+			t.Errorf("got %+v, want every package added", plan.Platforms)
+		}
+	})
+}
+
+func TestFormatOSLibsPlan(t *testing.T) {
+	plan := &osLibsPlan{
+		Combinations: []osLibsComboPlan{
+			{
+				Extension: "postgis", Distribution: "trixie", PgMajor: "18",
+				Origin: "postgis/system-libs/18-trixie-os-libs.lock.json",
+				Platforms: []osLibsPlatformDiff{
+					{Platform: "linux/amd64"},
+					{
+						Platform: "linux/arm64",
+						Upgraded: []osLibsVersionChange{
+							{Name: "libsqlite3-0", Arch: "arm64", FromVersion: "3.46.1-2", ToVersion: "3.46.1-3"},
+						},
+					},
+				},
+			},
+			{
+				Extension: "pgvector", Distribution: "trixie", PgMajor: "18",
+				Platforms: []osLibsPlatformDiff{{Platform: "linux/amd64"}},
+			},
+		},
+	}
+
+	got := formatOSLibsPlan(plan)
+	for _, want := range []string{
+		"## postgis (PostgreSQL 18 on trixie)",
+		"### linux/arm64",
+		"| Upgraded | `libsqlite3-0` | 3.46.1-2 | 3.46.1-3 |",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("output %q does not contain %q", got, want)
+		}
+	}
+	for _, unwanted := range []string{"pgvector", "### linux/amd64"} {
+		if strings.Contains(got, unwanted) {
+			t.Errorf("output %q contains %q", got, unwanted)
+		}
+	}
+
+	stale := formatOSLibsPlan(&osLibsPlan{Stale: []string{"postgis/system-libs/18-bookworm-os-libs.txt"}})
+	if !strings.Contains(stale, "- `postgis/system-libs/18-bookworm-os-libs.txt`") {
+		t.Errorf("output %q does not list the stale file", stale)
+	}
+
+	skipped := formatOSLibsPlan(&osLibsPlan{Combinations: []osLibsComboPlan{{
+		Extension: "pgvector", Distribution: "trixie", PgMajor: "19",
+		Skipped: osLibsUnpackaged, Reason: "package not found: postgresql-19-pgvector",
+	}}})
+	want := "- pgvector (PostgreSQL 19 on trixie): unpackaged, package not found: postgresql-19-pgvector\n"
+	if !strings.Contains(skipped, want) || strings.Contains(skipped, "## pgvector") {
+		t.Errorf("output %q does not only list the skipped combination", skipped)
+	}
+
+	if got := formatOSLibsPlan(&osLibsPlan{}); got != "No OS libraries changes.\n" {
+		t.Errorf("got %q, want no changes", got)
+	}
+	if _, err := renderOSLibsPlan(plan, "yaml"); err == nil {
+		t.Error("expected an error for an unsupported format")
+	}
+}
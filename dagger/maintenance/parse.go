@@ -5,9 +5,13 @@ import (
 	"context"
 	"fmt"
 	"slices"
+	"strings"
 
 	"github.com/hashicorp/hcl/v2"
 	"github.com/hashicorp/hcl/v2/hclsimple"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/convert"
+	"github.com/zclconf/go-cty/cty/gocty"
 
 	"dagger/maintenance/internal/dagger"
 )
@@ -40,42 +44,51 @@ func (m *buildMatrix) contains(distribution, majorVersion string) bool {
 }
 
 type extensionVersion struct {
-	Package string `hcl:"package" cty:"package"`
+	Package string `hcl:"package" cty:"package" description:"Version of the Debian package in the pgdg repository, kept up to date by renovate"`
+	SQL     string `hcl:"sql,optional" cty:"sql" description:"Version of the SQL extension shipped by the package, when it uses CREATE EXTENSION"`
 }
 
 type versionMap map[string]map[string]extensionVersion
 
 type extensionMetadata struct {
-	Name                   string            `hcl:"name" cty:"name"`
-	SQLName                string            `hcl:"sql_name" cty:"sql_name"`
-	ImageName              string            `hcl:"image_name" cty:"image_name"`
-	SharedPreloadLibraries []string          `hcl:"shared_preload_libraries" cty:"shared_preload_libraries"`
-	PostgresqlParameters   map[string]string `hcl:"postgresql_parameters" cty:"postgresql_parameters"`
-	ExtensionControlPath   []string          `hcl:"extension_control_path" cty:"extension_control_path"`
-	DynamicLibraryPath     []string          `hcl:"dynamic_library_path" cty:"dynamic_library_path"`
-	LdLibraryPath          []string          `hcl:"ld_library_path" cty:"ld_library_path"`
-	BinPath                []string          `hcl:"bin_path" cty:"bin_path"`
-	Env                    map[string]string `hcl:"env" cty:"env"`
-	AutoUpdateOsLibs       bool              `hcl:"auto_update_os_libs" cty:"auto_update_os_libs"`
-	RequiredExtensions     []string          `hcl:"required_extensions" cty:"required_extensions"`
-	CreateExtension        bool              `hcl:"create_extension" cty:"create_extension"`
-	Versions               versionMap        `hcl:"versions" cty:"versions"`
+	Name                   string            `hcl:"name" cty:"name" pattern:"^[a-z0-9_-]+$" description:"Name of the extension, matching its folder"`
+	SQLName                string            `hcl:"sql_name" cty:"sql_name" pattern:"^[a-z0-9_-]+$" description:"Name of the extension in CREATE EXTENSION"`
+	ImageName              string            `hcl:"image_name" cty:"image_name" pattern:"^[a-z0-9_-]+$" description:"Name of the image, unique across the container registry (ghcr.io/cloudnative-pg/<image_name>)"`
+	Packages               []string          `hcl:"packages" cty:"packages" pattern:"^[a-z0-9]([a-z0-9+.-]|%version%)*$" description:"Debian packages installed in the image, where %version% stands for the PostgreSQL major"`
+	Licenses               []string          `hcl:"licenses" cty:"licenses" pattern:"^[A-Za-z0-9.+-]+$" description:"SPDX identifiers of the licenses of the extension"`
+	SharedPreloadLibraries []string          `hcl:"shared_preload_libraries" cty:"shared_preload_libraries" description:"Libraries to add to shared_preload_libraries, used in tests"`
+	PostgresqlParameters   map[string]string `hcl:"postgresql_parameters" cty:"postgresql_parameters" description:"PostgreSQL parameters strictly needed by the extension, used in tests"`
+	ExtensionControlPath   []string          `hcl:"extension_control_path" cty:"extension_control_path" description:"Directories of the image to add to extension_control_path, the share directory when empty"`
+	DynamicLibraryPath     []string          `hcl:"dynamic_library_path" cty:"dynamic_library_path" description:"Directories of the image to add to dynamic_library_path, the lib directory when empty"`
+	LdLibraryPath          []string          `hcl:"ld_library_path" cty:"ld_library_path" description:"Directories of the image to add to LD_LIBRARY_PATH, such as the system libraries"`
+	BinPath                []string          `hcl:"bin_path" cty:"bin_path" description:"Directories of the image to add to PATH"`
+	Env                    map[string]string `hcl:"env" cty:"env" description:"Environment variables of the PostgreSQL process, where $${...} passes an operator placeholder"`
+	AutoUpdateOsLibs       bool              `hcl:"auto_update_os_libs" cty:"auto_update_os_libs" description:"Whether the maintenance tooling updates the OS libraries automatically"`
+	RequiredExtensions     []string          `hcl:"required_extensions" cty:"required_extensions" pattern:"^[a-z0-9_-]+$" description:"Folders of the extensions this extension requires"`
+	CreateExtension        bool              `hcl:"create_extension" cty:"create_extension" description:"Whether the tests run CREATE EXTENSION"`
+	Trusted                *bool             `hcl:"trusted,optional" cty:"trusted" description:"Expected trusted property of the control file, not checked when omitted"`
+	Superuser              *bool             `hcl:"superuser,optional" cty:"superuser" description:"Expected superuser property of the control file, not checked when omitted"`
+	Relocatable            *bool             `hcl:"relocatable,optional" cty:"relocatable" description:"Expected relocatable property of the control file, not checked when omitted"`
+	Versions               versionMap        `hcl:"versions"`
 	Remain                 hcl.Body          `hcl:",remain"`
+
+	// RawVersions holds the undecoded versions map: the "sql" attribute is
+	// optional, which can't be expressed when decoding straight into a struct.
+	RawVersions map[string]map[string]map[string]string `cty:"versions" description:"Versions of the packages, by distribution and PostgreSQL major"`
 }
 
 const (
 	metadataFile = "metadata.hcl"
+	// versionPlaceholder is replaced by the PG major in the package names
+	versionPlaceholder = "%version%"
 )
 
-// parseBuildMatrix derives the build matrix for a target extension by reading
-// its metadata.hcl from the source directory.
-func parseBuildMatrix(ctx context.Context, source *dagger.Directory, target string) (*buildMatrix, error) {
-	metadata, err := parseExtensionMetadata(ctx, source.Directory(target))
-	if err != nil {
-		return nil, err
-	}
-
-	return buildMatrixFromMetadata(metadata), nil
+// optionalMetadataAttributes are the metadata attributes which can be omitted,
+// with their types. They're decoded as nil when missing.
+var optionalMetadataAttributes = map[string]cty.Type{
+	"trusted":     cty.Bool,
+	"superuser":   cty.Bool,
+	"relocatable": cty.Bool,
 }
 
 // buildMatrixFromMetadata derives the build matrix (distributions and PG majors)
@@ -104,11 +117,6 @@ func buildMatrixFromMetadata(metadata *extensionMetadata) *buildMatrix {
 }
 
 func parseExtensionMetadata(ctx context.Context, extensionDirectory *dagger.Directory) (*extensionMetadata, error) {
-	type Config struct {
-		Metadata extensionMetadata `hcl:"metadata"`
-		Remain   hcl.Body          `hcl:",remain"`
-	}
-
 	hasMetadataFile, err := extensionDirectory.Exists(ctx, metadataFile)
 	if err != nil {
 		return nil, err
@@ -122,11 +130,117 @@ func parseExtensionMetadata(ctx context.Context, extensionDirectory *dagger.Dire
 		return nil, err
 	}
 
+	return decodeExtensionMetadata([]byte(data))
+}
+
+// requiredExtensionSQLNames returns the SQL names of the extensions listed in
+// required_extensions, read from the metadata of the sibling folders.
+func requiredExtensionSQLNames(ctx context.Context, source *dagger.Directory, metadata *extensionMetadata) ([]string, error) {
+	sqlNames := make([]string, 0, len(metadata.RequiredExtensions))
+	for _, dep := range metadata.RequiredExtensions {
+		depMetadata, err := parseExtensionMetadata(ctx, source.Directory(dep))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse dependency metadata %q: %w", dep, err)
+		}
+		sqlNames = append(sqlNames, depMetadata.SQLName)
+	}
+
+	return sqlNames, nil
+}
+
+// decodeExtensionMetadata decodes the content of a metadata.hcl file.
+func decodeExtensionMetadata(data []byte) (*extensionMetadata, error) {
+	type Config struct {
+		Metadata cty.Value `hcl:"metadata"`
+		Remain   hcl.Body  `hcl:",remain"`
+	}
+
 	var rootMeta Config
-	err = hclsimple.Decode(metadataFile, []byte(data), nil, &rootMeta)
+	err := hclsimple.Decode(metadataFile, data, nil, &rootMeta)
+	if err != nil {
+		return nil, err
+	}
+
+	value, err := withOptionalAttributes(rootMeta.Metadata, optionalMetadataAttributes)
+	if err != nil {
+		return nil, err
+	}
+
+	var metadata extensionMetadata
+	metadataType, err := gocty.ImpliedType(&metadata)
 	if err != nil {
 		return nil, err
 	}
+	value, err = convert.Convert(value, metadataType)
+	if err != nil {
+		return nil, fmt.Errorf("while decoding metadata: %w", err)
+	}
+	if err := gocty.FromCtyValue(value, &metadata); err != nil {
+		return nil, fmt.Errorf("while decoding metadata: %w", err)
+	}
+
+	metadata.Versions, err = decodeVersions(metadata.RawVersions)
+	if err != nil {
+		return nil, err
+	}
+
+	return &metadata, nil
+}
+
+// withOptionalAttributes adds a null value for each optional attribute missing
+// from an object, since decoding an object into a struct requires every
+// attribute to be set.
+func withOptionalAttributes(value cty.Value, optional map[string]cty.Type) (cty.Value, error) {
+	if value.IsNull() || !value.IsKnown() || !value.Type().IsObjectType() {
+		return cty.NilVal, fmt.Errorf("metadata must be an object")
+	}
+
+	attributes := value.AsValueMap()
+	if attributes == nil {
+		attributes = make(map[string]cty.Value, len(optional))
+	}
+	for name, attributeType := range optional {
+		if _, ok := attributes[name]; !ok {
+			attributes[name] = cty.NullVal(attributeType)
+		}
+	}
+
+	return cty.ObjectVal(attributes), nil
+}
+
+// resolvePackages returns the Debian packages to install for a PG major,
+// replacing the version placeholder in the package names of the metadata.
+func resolvePackages(metadata *extensionMetadata, majorVersion string) ([]string, error) {
+	if len(metadata.Packages) == 0 {
+		return nil, fmt.Errorf("no packages declared in metadata for extension %s", metadata.Name)
+	}
+
+	packages := make([]string, 0, len(metadata.Packages))
+	for _, pkg := range metadata.Packages {
+		packages = append(packages, strings.ReplaceAll(pkg, versionPlaceholder, majorVersion))
+	}
+
+	return packages, nil
+}
+
+// decodeVersions converts the raw versions map into a versionMap,
+// making sure every distribution/major entry declares a package version.
+func decodeVersions(raw map[string]map[string]map[string]string) (versionMap, error) {
+	versions := make(versionMap, len(raw))
+	for distribution, versionsByMajor := range raw {
+		versions[distribution] = make(map[string]extensionVersion, len(versionsByMajor))
+		for majorVersion, attributes := range versionsByMajor {
+			pkg := attributes["package"]
+			if pkg == "" {
+				return nil, fmt.Errorf("missing package version for distribution %q and version %s",
+					distribution, majorVersion)
+			}
+			versions[distribution][majorVersion] = extensionVersion{
+				Package: pkg,
+				SQL:     attributes["sql"],
+			}
+		}
+	}
 
-	return &rootMeta.Metadata, nil
+	return versions, nil
 }
@@ -98,3 +98,88 @@ func TestBuildMatrix(t *testing.T) {
 		}
 	})
 }
+
+func TestDecodeExtensionMetadata(t *testing.T) {
+	data := []byte(`
+metadata = {
+  name                     = "pgvector"
+  sql_name                 = "vector"
+  image_name               = "pgvector"
+  packages                 = ["postgresql-%version%-pgvector"]
+  licenses                 = ["PostgreSQL"]
+  shared_preload_libraries = []
+  postgresql_parameters    = {}
+  extension_control_path   = []
+  dynamic_library_path     = []
+  ld_library_path          = []
+  bin_path                 = []
+  env                      = {}
+  auto_update_os_libs      = false
+  required_extensions      = []
+  create_extension         = true
+  trusted                  = true
+
+  versions = {
+    bookworm = {
+      "18" = {
+        // renovate: suite=bookworm-pgdg depName=postgresql-18-pgvector
+        package = "0.8.6-1.pgdg12+1"
+      }
+    }
+    trixie = {
+      "18" = {
+        // renovate: suite=trixie-pgdg depName=postgresql-18-pgvector
+        package = "0.8.6-1.pgdg13+1"
+        sql     = "0.8.6"
+      }
+    }
+  }
+}
+`)
+
+	metadata, err := decodeExtensionMetadata(data)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if metadata.SQLName != "vector" {
+		t.Errorf("SQLName: got %q, want %q", metadata.SQLName, "vector")
+	}
+	if metadata.Trusted == nil || !*metadata.Trusted {
+		t.Errorf("Trusted: got %v, want true", metadata.Trusted)
+	}
+	if metadata.Superuser != nil || metadata.Relocatable != nil {
+		t.Errorf("Superuser, Relocatable: got %v, %v, want nil", metadata.Superuser, metadata.Relocatable)
+	}
+
+	want := versionMap{
+		"bookworm": {"18": {Package: "0.8.6-1.pgdg12+1"}},
+		"trixie":   {"18": {Package: "0.8.6-1.pgdg13+1", SQL: "0.8.6"}},
+	}
+	for distribution, versionsByMajor := range want {
+		for major, version := range versionsByMajor {
+			if got := metadata.Versions[distribution][major]; got != version {
+				t.Errorf("Versions[%s][%s]: got %+v, want %+v", distribution, major, got, version)
+			}
+		}
+	}
+}
+
+func TestResolvePackages(t *testing.T) {
+	metadata := &extensionMetadata{
+		Name:     "postgis",
+		Packages: []string{"postgresql-%version%-postgis-3", "postgresql-%version%-postgis-3-scripts"},
+	}
+
+	got, err := resolvePackages(metadata, "18")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []string{"postgresql-18-postgis-3", "postgresql-18-postgis-3-scripts"}
+	if !slices.Equal(got, want) {
+		t.Errorf("resolvePackages() = %v, want %v", got, want)
+	}
+
+	if _, err := resolvePackages(&extensionMetadata{Name: "empty"}, "18"); err == nil {
+		t.Error("expected an error when no package is declared")
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// renovateAnnotation is a renovate comment of metadata.hcl, such as
+// "// renovate: suite=trixie-pgdg depName=postgresql-18-pgvector extractVersion=^(?<version>\d+\.\d+\.\d+)"
+type renovateAnnotation struct {
+	Suite          string
+	DepName        string
+	ExtractVersion string
+}
+
+// parseRenovateAnnotation parses a renovate comment, reporting whether the
+// comment is one.
+func parseRenovateAnnotation(comment string) (*renovateAnnotation, bool) {
+	comment = strings.TrimSpace(comment)
+	for _, prefix := range []string{"//", "#"} {
+		comment = strings.TrimPrefix(comment, prefix)
+	}
+	rest, ok := strings.CutPrefix(strings.TrimSpace(comment), "renovate:")
+	if !ok {
+		return nil, false
+	}
+
+	annotation := &renovateAnnotation{}
+	for _, field := range strings.Fields(rest) {
+		key, value, _ := strings.Cut(field, "=")
+		switch key {
+		case "suite":
+			annotation.Suite = value
+		case "depName":
+			annotation.DepName = value
+		case "extractVersion":
+			annotation.ExtractVersion = value
+		}
+	}
+
+	return annotation, true
+}
+
+// extractVersion applies the extractVersion rule of a renovate annotation to a
+// package version, returning its "version" named group.
+func extractVersion(pattern string, packageVersion string) (string, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return "", fmt.Errorf("invalid extractVersion %q: %w", pattern, err)
+	}
+	index := re.SubexpIndex("version")
+	if index < 0 {
+		return "", fmt.Errorf("extractVersion %q has no \"version\" group", pattern)
+	}
+	matches := re.FindStringSubmatch(packageVersion)
+	if matches == nil || matches[index] == "" {
+		return "", fmt.Errorf("extractVersion %q doesn't match %q", pattern, packageVersion)
+	}
+
+	return matches[index], nil
+}
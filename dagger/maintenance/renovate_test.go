@@ -0,0 +1,51 @@
+package main
+
+import "testing"
+
+func TestParseRenovateAnnotation(t *testing.T) {
+	annotation, ok := parseRenovateAnnotation(
+		"// renovate: suite=trixie-pgdg depName=postgresql-18-pgvector extractVersion=^(?<version>\\d+\\.\\d+)\n")
+	if !ok {
+		t.Fatal("expected a renovate annotation")
+	}
+	want := renovateAnnotation{
+		Suite:          "trixie-pgdg",
+		DepName:        "postgresql-18-pgvector",
+		ExtractVersion: `^(?<version>\d+\.\d+)`,
+	}
+	if *annotation != want {
+		t.Errorf("got %+v, want %+v", *annotation, want)
+	}
+
+	if _, ok := parseRenovateAnnotation("// TODO: adjust the regex"); ok {
+		t.Error("expected no renovate annotation")
+	}
+}
+
+func TestExtractVersion(t *testing.T) {
+	tests := []struct {
+		pattern string
+		version string
+		want    string
+		wantErr bool
+	}{
+		{pattern: `^(?<version>\d+\.\d+\.\d+)`, version: "3.6.4+dfsg-2.pgdg13+1", want: "3.6.4"},
+		{pattern: `^(?<version>\d+\.\d+)`, version: "18.0-3.pgdg12+1", want: "18.0"},
+		{pattern: `^(?<version>\d+\.\d+\.\d+)`, version: "1.13-1.pgdg13+1", wantErr: true},
+		{pattern: `^(\d+\.\d+)`, version: "1.13-1.pgdg13+1", wantErr: true},
+		{pattern: `^(?<version>\d+`, version: "1.13-1.pgdg13+1", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := extractVersion(tt.pattern, tt.version)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("extractVersion(%q, %q): expected an error", tt.pattern, tt.version)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("extractVersion(%q, %q) = %q, %v, want %q", tt.pattern, tt.version, got, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,373 @@
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+// sbomPackage is a package listed in the SBOM of an image, normalised from
+// its package URL.
+type sbomPackage struct {
+	Type    string `json:"type"`
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	Arch    string `json:"arch,omitempty"`
+	// Source is the source package the package was built from, if known
+	Source string `json:"source,omitempty"`
+	PURL   string `json:"purl"`
+}
+
+// platformSBOM holds the packages listed in the SBOM of a single platform image.
+type platformSBOM struct {
+	Platform string        `json:"platform"`
+	Packages []sbomPackage `json:"packages"`
+}
+
+// imageSBOM holds the packages listed in the SBOMs of an image.
+type imageSBOM struct {
+	Reference string         `json:"reference"`
+	Platforms []platformSBOM `json:"platforms"`
+}
+
+// sbomDiff holds the package differences between the SBOMs of two images.
+type sbomDiff struct {
+	From      string             `json:"from"`
+	To        string             `json:"to"`
+	Platforms []platformSBOMDiff `json:"platforms"`
+}
+
+// platformSBOMDiff holds the package differences of a single platform.
+type platformSBOMDiff struct {
+	Platform string `json:"platform"`
+	// Missing is set to the image lacking the platform, if any
+	Missing string          `json:"missing,omitempty"`
+	Added   []sbomPackage   `json:"added,omitempty"`
+	Removed []sbomPackage   `json:"removed,omitempty"`
+	Changed []packageChange `json:"changed,omitempty"`
+}
+
+// packageChange is a package whose version changed between two images.
+type packageChange struct {
+	Type        string `json:"type"`
+	Name        string `json:"name"`
+	Arch        string `json:"arch,omitempty"`
+	FromVersion string `json:"fromVersion"`
+	ToVersion   string `json:"toVersion"`
+}
+
+// inTotoStatement is the envelope of a BuildKit attestation.
+type inTotoStatement struct {
+	PredicateType string          `json:"predicateType"`
+	Predicate     json.RawMessage `json:"predicate"`
+}
+
+// spdxDocument holds the part of an SPDX document relevant to the package list.
+type spdxDocument struct {
+	Packages []struct {
+		Name         string `json:"name"`
+		VersionInfo  string `json:"versionInfo"`
+		ExternalRefs []struct {
+			ReferenceType    string `json:"referenceType"`
+			ReferenceLocator string `json:"referenceLocator"`
+		} `json:"externalRefs"`
+	} `json:"packages"`
+}
+
+// getSBOMs returns, for each platform of an image index, the SPDX documents
+// attached as BuildKit SBOM attestation layers. BuildKit writes one layer per
+// scanned target, such as the image root filesystem and each build context.
+func getSBOMs(imageRef string, opts ...remote.Option) (map[string][][]byte, error) {
+	ref, err := name.ParseReference(imageRef, name.Insecure)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err := remote.Index(ref, opts...)
+	if err != nil {
+		return nil, err
+	}
+	indexManifest, err := index.IndexManifest()
+	if err != nil {
+		return nil, err
+	}
+
+	platforms := make(map[string]string)
+	for _, descriptor := range indexManifest.Manifests {
+		if descriptor.Platform != nil && descriptor.Platform.OS != "unknown" {
+			platforms[descriptor.Digest.String()] = formatPlatform(descriptor.Platform)
+		}
+	}
+
+	sboms := make(map[string][][]byte)
+	for _, descriptor := range indexManifest.Manifests {
+		if descriptor.Annotations[annotationReferenceType] != attestationManifestType {
+			continue
+		}
+		platform, ok := platforms[descriptor.Annotations[annotationReferenceDigest]]
+		if !ok {
+			continue
+		}
+
+		attestation, err := index.Image(descriptor.Digest)
+		if err != nil {
+			return nil, fmt.Errorf("while fetching attestation manifest %s: %w", descriptor.Digest, err)
+		}
+		manifest, err := attestation.Manifest()
+		if err != nil {
+			return nil, fmt.Errorf("while fetching attestation manifest %s: %w", descriptor.Digest, err)
+		}
+
+		for _, layerDescriptor := range manifest.Layers {
+			if !strings.HasPrefix(layerDescriptor.Annotations[annotationPredicateType], predicateTypeSBOM) {
+				continue
+			}
+			layer, err := attestation.LayerByDigest(layerDescriptor.Digest)
+			if err != nil {
+				return nil, fmt.Errorf("while fetching SBOM %s: %w", layerDescriptor.Digest, err)
+			}
+			// Attestation layers are plain in-toto statements, read them as they are stored
+			reader, err := layer.Compressed()
+			if err != nil {
+				return nil, fmt.Errorf("while fetching SBOM %s: %w", layerDescriptor.Digest, err)
+			}
+			data, err := io.ReadAll(reader)
+			_ = reader.Close()
+			if err != nil {
+				return nil, fmt.Errorf("while reading SBOM %s: %w", layerDescriptor.Digest, err)
+			}
+			sboms[platform] = append(sboms[platform], data)
+		}
+	}
+	if len(sboms) == 0 {
+		return nil, fmt.Errorf("no SBOM attestation found in %s", imageRef)
+	}
+
+	return sboms, nil
+}
+
+// readImageSBOM fetches and normalises the SBOMs of every platform of an image,
+// merging the packages of the SBOM layers of each platform.
+func readImageSBOM(imageRef string, opts ...remote.Option) (*imageSBOM, error) {
+	sboms, err := getSBOMs(imageRef, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &imageSBOM{Reference: imageRef}
+	for _, platform := range slices.Sorted(maps.Keys(sboms)) {
+		var packages []sbomPackage
+		for _, data := range sboms[platform] {
+			layerPackages, err := parseSBOM(data)
+			if err != nil {
+				return nil, fmt.Errorf("extension image %s (%s): %w", imageRef, platform, err)
+			}
+			packages = mergeSBOMPackages(packages, layerPackages)
+		}
+		result.Platforms = append(result.Platforms, platformSBOM{Platform: platform, Packages: packages})
+	}
+
+	return result, nil
+}
+
+// parseSBOM normalises the SPDX document of an in-toto statement to the list of
+// packages identified by a package URL, sorted by type, name and architecture.
+func parseSBOM(data []byte) ([]sbomPackage, error) {
+	var statement inTotoStatement
+	if err := json.Unmarshal(data, &statement); err != nil {
+		return nil, fmt.Errorf("while decoding in-toto statement: %w", err)
+	}
+	if !strings.HasPrefix(statement.PredicateType, predicateTypeSBOM) {
+		return nil, fmt.Errorf("unsupported predicate type %q", statement.PredicateType)
+	}
+
+	var document spdxDocument
+	if err := json.Unmarshal(statement.Predicate, &document); err != nil {
+		return nil, fmt.Errorf("while decoding SPDX document: %w", err)
+	}
+
+	var packages []sbomPackage
+	for _, spdxPackage := range document.Packages {
+		for _, ref := range spdxPackage.ExternalRefs {
+			if ref.ReferenceType != "purl" {
+				continue
+			}
+			pkg, err := parsePURL(ref.ReferenceLocator)
+			if err != nil {
+				return nil, fmt.Errorf("package %s: %w", spdxPackage.Name, err)
+			}
+			if pkg.Version == "" {
+				pkg.Version = spdxPackage.VersionInfo
+			}
+			if !slices.Contains(packages, *pkg) {
+				packages = append(packages, *pkg)
+			}
+			break
+		}
+	}
+	slices.SortFunc(packages, compareSBOMPackages)
+
+	return packages, nil
+}
+
+// mergeSBOMPackages returns the packages of both lists, without duplicates,
+// sorted by type, name and architecture.
+func mergeSBOMPackages(packages []sbomPackage, others []sbomPackage) []sbomPackage {
+	merged := slices.Clone(packages)
+	for _, pkg := range others {
+		if !slices.Contains(merged, pkg) {
+			merged = append(merged, pkg)
+		}
+	}
+	slices.SortFunc(merged, compareSBOMPackages)
+
+	return merged
+}
+
+// parsePURL parses a package URL (pkg:type/namespace/name@version?qualifiers).
+func parsePURL(purl string) (*sbomPackage, error) {
+	rest, ok := strings.CutPrefix(purl, "pkg:")
+	if !ok {
+		return nil, fmt.Errorf("invalid package URL %q", purl)
+	}
+	rest, _, _ = strings.Cut(rest, "#")
+	rest, rawQualifiers, _ := strings.Cut(rest, "?")
+	rest, rawVersion, _ := strings.Cut(rest, "@")
+
+	purlType, path, ok := strings.Cut(rest, "/")
+	if !ok || purlType == "" || path == "" {
+		return nil, fmt.Errorf("invalid package URL %q", purl)
+	}
+	rawName := path[strings.LastIndexByte(path, '/')+1:]
+
+	packageName, err := url.PathUnescape(rawName)
+	if err != nil {
+		return nil, fmt.Errorf("invalid package URL %q: %w", purl, err)
+	}
+	version, err := url.PathUnescape(rawVersion)
+	if err != nil {
+		return nil, fmt.Errorf("invalid package URL %q: %w", purl, err)
+	}
+	qualifiers, err := url.ParseQuery(rawQualifiers)
+	if err != nil {
+		return nil, fmt.Errorf("invalid package URL %q: %w", purl, err)
+	}
+	source, _, _ := strings.Cut(qualifiers.Get("upstream"), "@")
+
+	return &sbomPackage{
+		Type:    strings.ToLower(purlType),
+		Name:    packageName,
+		Version: version,
+		Arch:    qualifiers.Get("arch"),
+		Source:  source,
+		PURL:    purl,
+	}, nil
+}
+
+// compareSBOMPackages orders packages by type, name, architecture and version.
+func compareSBOMPackages(a, b sbomPackage) int {
+	return cmp.Or(
+		cmp.Compare(a.Type, b.Type),
+		cmp.Compare(a.Name, b.Name),
+		cmp.Compare(a.Arch, b.Arch),
+		cmp.Compare(a.Version, b.Version),
+	)
+}
+
+// diffSBOMPackages compares the packages of two SBOMs of the same platform.
+// Packages are identified by type, name and architecture.
+func diffSBOMPackages(platform string, from, to []sbomPackage) platformSBOMDiff {
+	diff := platformSBOMDiff{Platform: platform}
+	key := func(pkg sbomPackage) string {
+		return pkg.Type + "/" + pkg.Name + "/" + pkg.Arch
+	}
+
+	toPackages := make(map[string]sbomPackage, len(to))
+	for _, pkg := range to {
+		toPackages[key(pkg)] = pkg
+	}
+	fromPackages := make(map[string]sbomPackage, len(from))
+	for _, pkg := range from {
+		fromPackages[key(pkg)] = pkg
+
+		toPkg, ok := toPackages[key(pkg)]
+		switch {
+		case !ok:
+			diff.Removed = append(diff.Removed, pkg)
+		case toPkg.Version != pkg.Version:
+			diff.Changed = append(diff.Changed, packageChange{
+				Type:        pkg.Type,
+				Name:        pkg.Name,
+				Arch:        pkg.Arch,
+				FromVersion: pkg.Version,
+				ToVersion:   toPkg.Version,
+			})
+		}
+	}
+	for _, pkg := range to {
+		if _, ok := fromPackages[key(pkg)]; !ok {
+			diff.Added = append(diff.Added, pkg)
+		}
+	}
+
+	return diff
+}
+
+// renderSBOMDiff renders the package differences between two images in the
+// given format, either "markdown" or "json".
+func renderSBOMDiff(diff *sbomDiff, format string) (string, error) {
+	switch format {
+	case "json":
+		data, err := json.MarshalIndent(diff, "", "  ")
+		if err != nil {
+			return "", err
+		}
+		return string(data) + "\n", nil
+	case "markdown":
+		return formatSBOMDiff(diff), nil
+	}
+
+	return "", fmt.Errorf("unsupported format %q, must be either markdown or json", format)
+}
+
+// formatSBOMDiff renders the package differences between two images in Markdown,
+// suitable for security reviews.
+func formatSBOMDiff(diff *sbomDiff) string {
+	var out strings.Builder
+	fmt.Fprintf(&out, "## Package changes from `%s` to `%s`\n", diff.From, diff.To)
+
+	for _, platform := range diff.Platforms {
+		fmt.Fprintf(&out, "\n### %s\n\n", platform.Platform)
+		if platform.Missing != "" {
+			fmt.Fprintf(&out, "SBOM not available in `%s`.\n", platform.Missing)
+			continue
+		}
+		if len(platform.Added) == 0 && len(platform.Removed) == 0 && len(platform.Changed) == 0 {
+			fmt.Fprintf(&out, "No package changes.\n")
+			continue
+		}
+
+		fmt.Fprintf(&out, "| Change | Package | Type | From | To |\n")
+		fmt.Fprintf(&out, "|---|---|---|---|---|\n")
+		for _, pkg := range platform.Added {
+			fmt.Fprintf(&out, "| Added | `%s` | %s | | %s |\n", pkg.Name, pkg.Type, pkg.Version)
+		}
+		for _, pkg := range platform.Removed {
+			fmt.Fprintf(&out, "| Removed | `%s` | %s | %s | |\n", pkg.Name, pkg.Type, pkg.Version)
+		}
+		for _, change := range platform.Changed {
+			fmt.Fprintf(&out, "| Changed | `%s` | %s | %s | %s |\n",
+				change.Name, change.Type, change.FromVersion, change.ToVersion)
+		}
+	}
+
+	return out.String()
+}
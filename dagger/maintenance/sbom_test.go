@@ -0,0 +1,141 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+const testSBOM = `{
+  "_type": "https://in-toto.io/Statement/v0.1",
+  "predicateType": "https://spdx.dev/Document",
+  "predicate": {
+    "spdxVersion": "SPDX-2.3",
+    "packages": [
+      {
+        "name": "libgeos-c1t64",
+        "versionInfo": "3.13.1-1",
+        "externalRefs": [
+          {"referenceCategory": "SECURITY", "referenceType": "cpe23Type", "referenceLocator": "cpe:2.3:a:libgeos-c1t64:libgeos-c1t64:3.13.1-1:*:*:*:*:*:*:*"},
+          {"referenceCategory": "PACKAGE-MANAGER", "referenceType": "purl", "referenceLocator": "pkg:deb/debian/libgeos-c1t64@3.13.1-1?arch=amd64&upstream=geos&distro=debian-13"}
+        ]
+      },
+      {
+        "name": "libarmadillo14",
+        "versionInfo": "1:14.2.3+dfsg-1+b1",
+        "externalRefs": [
+          {"referenceCategory": "PACKAGE-MANAGER", "referenceType": "purl", "referenceLocator": "pkg:deb/debian/libarmadillo14@1%3A14.2.3%2Bdfsg-1%2Bb1?arch=amd64&upstream=armadillo%401%3A14.2.3%2Bdfsg-1"}
+        ]
+      },
+      {"name": "/lib/postgis-3.so", "versionInfo": "NOASSERTION"}
+    ]
+  }
+}`
+
+func TestParseSBOM(t *testing.T) {
+	packages, err := parseSBOM([]byte(testSBOM))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []sbomPackage{
+		{
+			Type:    "deb",
+			Name:    "libarmadillo14",
+			Version: "1:14.2.3+dfsg-1+b1",
+			Arch:    "amd64",
+			Source:  "armadillo",
+			PURL:    "pkg:deb/debian/libarmadillo14@1%3A14.2.3%2Bdfsg-1%2Bb1?arch=amd64&upstream=armadillo%401%3A14.2.3%2Bdfsg-1",
+		},
+		{
+			Type:    "deb",
+			Name:    "libgeos-c1t64",
+			Version: "3.13.1-1",
+			Arch:    "amd64",
+			Source:  "geos",
+			PURL:    "pkg:deb/debian/libgeos-c1t64@3.13.1-1?arch=amd64&upstream=geos&distro=debian-13",
+		},
+	}
+	if !slices.Equal(packages, want) {
+		t.Errorf("parseSBOM() = %+v, want %+v", packages, want)
+	}
+
+	if _, err := parseSBOM([]byte(`{"predicateType": "https://slsa.dev/provenance/v0.2"}`)); err == nil {
+		t.Error("expected an error for a provenance attestation")
+	}
+}
+
+func TestMergeSBOMPackages(t *testing.T) {
+	rootfs := []sbomPackage{
+		{Type: "deb", Name: "libgeos-c1t64", Version: "3.13.1-1", Arch: "amd64"},
+		{Type: "deb", Name: "postgresql-18-postgis-3", Version: "3.6.0+dfsg-1.pgdg13+1", Arch: "amd64"},
+	}
+	context := []sbomPackage{
+		{Type: "deb", Name: "libgeos-c1t64", Version: "3.13.1-1", Arch: "amd64"},
+		{Type: "deb", Name: "libproj25", Version: "9.6.0-1", Arch: "amd64"},
+	}
+
+	got := mergeSBOMPackages(rootfs, context)
+	want := []sbomPackage{
+		{Type: "deb", Name: "libgeos-c1t64", Version: "3.13.1-1", Arch: "amd64"},
+		{Type: "deb", Name: "libproj25", Version: "9.6.0-1", Arch: "amd64"},
+		{Type: "deb", Name: "postgresql-18-postgis-3", Version: "3.6.0+dfsg-1.pgdg13+1", Arch: "amd64"},
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+	if len(rootfs) != 2 {
+		t.Errorf("mergeSBOMPackages() modified its argument: %+v", rootfs)
+	}
+}
+
+func TestParsePURL(t *testing.T) {
+	for _, purl := range []string{"deb/debian/libc6@2.41", "pkg:deb", "pkg:/libc6"} {
+		if _, err := parsePURL(purl); err == nil {
+			t.Errorf("parsePURL(%q): expected an error", purl)
+		}
+	}
+
+	pkg, err := parsePURL("pkg:golang/github.com/google/go-containerregistry@v0.20.6")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if pkg.Type != "golang" || pkg.Name != "go-containerregistry" || pkg.Version != "v0.20.6" {
+		t.Errorf("parsePURL() = %+v", pkg)
+	}
+}
+
+func TestDiffSBOMPackages(t *testing.T) {
+	from := []sbomPackage{
+		{Type: "deb", Name: "libgdal36", Version: "3.10.3+dfsg-1", Arch: "amd64"},
+		{Type: "deb", Name: "libgeos-c1t64", Version: "3.13.1-1", Arch: "amd64"},
+		{Type: "deb", Name: "libproj25", Version: "9.6.0-1", Arch: "amd64"},
+	}
+	to := []sbomPackage{
+		{Type: "deb", Name: "libgdal37", Version: "3.11.0+dfsg-1", Arch: "amd64"},
+		{Type: "deb", Name: "libgeos-c1t64", Version: "3.13.1-2", Arch: "amd64"},
+		{Type: "deb", Name: "libproj25", Version: "9.6.0-1", Arch: "amd64"},
+	}
+
+	diff := diffSBOMPackages("linux/amd64", from, to)
+	if len(diff.Added) != 1 || diff.Added[0].Name != "libgdal37" {
+		t.Errorf("Added: got %+v", diff.Added)
+	}
+	if len(diff.Removed) != 1 || diff.Removed[0].Name != "libgdal36" {
+		t.Errorf("Removed: got %+v", diff.Removed)
+	}
+	wantChanged := []packageChange{
+		{Type: "deb", Name: "libgeos-c1t64", Arch: "amd64", FromVersion: "3.13.1-1", ToVersion: "3.13.1-2"},
+	}
+	if !slices.Equal(diff.Changed, wantChanged) {
+		t.Errorf("Changed: got %+v, want %+v", diff.Changed, wantChanged)
+	}
+
+	markdown, err := renderSBOMDiff(&sbomDiff{From: "a", To: "b", Platforms: []platformSBOMDiff{diff}}, "markdown")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(markdown, "| Changed | `libgeos-c1t64` | deb | 3.13.1-1 | 3.13.1-2 |") {
+		t.Errorf("unexpected markdown:\n%s", markdown)
+	}
+}
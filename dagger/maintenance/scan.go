@@ -0,0 +1,254 @@
+package main
+
+import (
+	"bufio"
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// osLibsFileRegex matches the name of the OS libraries files: <major>-<distribution>-os-libs.txt
+var osLibsFileRegex = regexp.MustCompile(`^(\d+)-([a-z]+)-os-libs\.txt$`)
+
+// osLibsLockFileRegex matches the name of the OS libraries lockfiles: <major>-<distribution>-os-libs.lock.json
+var osLibsLockFileRegex = regexp.MustCompile(`^(\d+)-([a-z]+)-os-libs\.lock\.json$`)
+
+// osPackage is a Debian package bundled in an extension image.
+type osPackage struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	Arch    string `json:"arch,omitempty"`
+	// Source is the source package, empty when unknown
+	Source string `json:"source,omitempty"`
+}
+
+// securityTracker is a snapshot of the Debian security tracker
+// (https://security-tracker.debian.org/tracker/data/json), keyed by source
+// package and CVE.
+type securityTracker map[string]map[string]securityTrackerIssue
+
+// securityTrackerIssue is an issue of the Debian security tracker.
+type securityTrackerIssue struct {
+	Description string                                  `json:"description"`
+	Releases    map[string]securityTrackerReleaseStatus `json:"releases"`
+}
+
+// securityTrackerReleaseStatus is the status of an issue in a Debian release.
+type securityTrackerReleaseStatus struct {
+	Status       string `json:"status"`
+	FixedVersion string `json:"fixed_version"`
+	Urgency      string `json:"urgency"`
+}
+
+// vulnerabilityFinding is a known vulnerability affecting a bundled package.
+type vulnerabilityFinding struct {
+	Package      string `json:"package"`
+	Version      string `json:"version"`
+	Source       string `json:"source"`
+	CVE          string `json:"cve"`
+	Status       string `json:"status"`
+	FixedVersion string `json:"fixedVersion,omitempty"`
+	Urgency      string `json:"urgency,omitempty"`
+}
+
+// osLibsScan is the result of the vulnerability scan of the packages bundled
+// for a distribution and PG major.
+type osLibsScan struct {
+	Extension    string                 `json:"extension"`
+	Distribution string                 `json:"distribution"`
+	PgMajor      string                 `json:"pgMajor"`
+	Origin       string                 `json:"origin"`
+	Packages     int                    `json:"packages"`
+	Findings     []vulnerabilityFinding `json:"findings"`
+	// Unresolved are the packages whose source package is unknown, which
+	// couldn't be matched against the tracker
+	Unresolved []string `json:"unresolved"`
+}
+
+// parseOSLibs parses the content of an OS libraries file, where each line
+// holds the file name of a Debian package (<name>_<version>_<arch>.deb)
+// optionally followed by its checksum.
+func parseOSLibs(content string) ([]osPackage, error) {
+	var packages []osPackage
+
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+
+		pkg, err := parseDebFileName(fields[0])
+		if err != nil {
+			return nil, err
+		}
+		packages = append(packages, *pkg)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return packages, nil
+}
+
+// parseDebFileName parses the file name of a Debian package, as printed by
+// apt-get --print-uris: <name>_<version>_<arch>.deb with an URL-encoded version.
+func parseDebFileName(fileName string) (*osPackage, error) {
+	parts := strings.Split(strings.TrimSuffix(fileName, ".deb"), "_")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("invalid package file name %q", fileName)
+	}
+	version, err := url.PathUnescape(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("invalid package file name %q: %w", fileName, err)
+	}
+
+	return &osPackage{Name: parts[0], Version: version, Arch: parts[2]}, nil
+}
+
+// parseSecurityTracker decodes a Debian security tracker snapshot.
+func parseSecurityTracker(data []byte) (securityTracker, error) {
+	var tracker securityTracker
+	if err := json.Unmarshal(data, &tracker); err != nil {
+		return nil, fmt.Errorf("while decoding the Debian security tracker snapshot: %w", err)
+	}
+
+	return tracker, nil
+}
+
+// sourcePackage returns the source package a package is built from: the
+// recorded one, or the package itself when the tracker knows a source package
+// of that name. It reports whether the source could be resolved: binary
+// package names are never mapped to a source by guessing.
+func (t securityTracker) sourcePackage(pkg osPackage) (string, bool) {
+	if pkg.Source != "" {
+		return pkg.Source, true
+	}
+	if _, ok := t[pkg.Name]; ok {
+		return pkg.Name, true
+	}
+
+	return "", false
+}
+
+// match returns the vulnerabilities of a Debian release affecting a package
+// built from the given source package, sorted by CVE.
+func (t securityTracker) match(release string, pkg osPackage, source string) []vulnerabilityFinding {
+	var findings []vulnerabilityFinding
+	issues := t[source]
+	for _, cve := range slices.Sorted(maps.Keys(issues)) {
+		status, ok := issues[cve].Releases[release]
+		if !ok {
+			continue
+		}
+
+		finding := vulnerabilityFinding{
+			Package: pkg.Name,
+			Version: pkg.Version,
+			Source:  source,
+			CVE:     cve,
+			Status:  status.Status,
+			Urgency: status.Urgency,
+		}
+		switch status.Status {
+		case "resolved":
+			// A fixed version of "0" means the release was never affected
+			if status.FixedVersion == "" || status.FixedVersion == "0" ||
+				compareDebianVersions(pkg.Version, status.FixedVersion) >= 0 {
+				continue
+			}
+			finding.FixedVersion = status.FixedVersion
+		case "open", "undetermined":
+		default:
+			continue
+		}
+		findings = append(findings, finding)
+	}
+
+	return findings
+}
+
+// scanPackages matches the packages bundled for a distribution against the tracker.
+func scanPackages(tracker securityTracker, scan *osLibsScan, packages []osPackage) {
+	scan.Packages = len(packages)
+	for _, pkg := range packages {
+		source, ok := tracker.sourcePackage(pkg)
+		if !ok {
+			scan.Unresolved = append(scan.Unresolved, pkg.Name)
+			continue
+		}
+		scan.Findings = append(scan.Findings, tracker.match(scan.Distribution, pkg, source)...)
+	}
+	slices.Sort(scan.Unresolved)
+	slices.SortFunc(scan.Findings, func(a, b vulnerabilityFinding) int {
+		return cmp.Or(cmp.Compare(a.Package, b.Package), cmp.Compare(a.CVE, b.CVE))
+	})
+}
+
+// checkResolvedScan returns an error if none of the packages of a scan could
+// be resolved to a source package, in which case the scan tells nothing about
+// their vulnerabilities.
+func checkResolvedScan(scan *osLibsScan) error {
+	if scan.Packages > 0 && len(scan.Unresolved) == scan.Packages {
+		return fmt.Errorf("none of the %d packages of %s could be resolved to a source package",
+			scan.Packages, scan.Origin)
+	}
+
+	return nil
+}
+
+// renderVulnerabilityScans renders the vulnerability scans in the given
+// format, either "markdown" or "json".
+func renderVulnerabilityScans(scans []*osLibsScan, format string) (string, error) {
+	switch format {
+	case "json":
+		data, err := json.MarshalIndent(scans, "", "  ")
+		if err != nil {
+			return "", err
+		}
+		return string(data) + "\n", nil
+	case "markdown":
+		return formatVulnerabilityScans(scans), nil
+	}
+
+	return "", fmt.Errorf("unsupported format %q, must be either markdown or json", format)
+}
+
+// formatVulnerabilityScans renders the vulnerability scans in Markdown.
+func formatVulnerabilityScans(scans []*osLibsScan) string {
+	var out strings.Builder
+	for i, scan := range scans {
+		if i > 0 {
+			fmt.Fprintln(&out)
+		}
+		fmt.Fprintf(&out, "## %s (PostgreSQL %s on %s)\n\n", scan.Extension, scan.PgMajor, scan.Distribution)
+		fmt.Fprintf(&out, "%d packages scanned from `%s`.\n", scan.Packages, scan.Origin)
+		if len(scan.Unresolved) > 0 {
+			fmt.Fprintf(&out, "\n**%d packages have an unknown source package, their vulnerabilities are unknown**: `%s`.\n",
+				len(scan.Unresolved), strings.Join(scan.Unresolved, "`, `"))
+		}
+		if len(scan.Findings) == 0 {
+			if len(scan.Unresolved) > 0 {
+				fmt.Fprintf(&out, "\nNo known vulnerabilities in the %d resolved packages, the unresolved ones weren't checked.\n",
+					scan.Packages-len(scan.Unresolved))
+			} else {
+				fmt.Fprintf(&out, "No known vulnerabilities.\n")
+			}
+			continue
+		}
+
+		fmt.Fprintf(&out, "\n| Package | Version | CVE | Status | Urgency | Fixed version |\n")
+		fmt.Fprintf(&out, "|---|---|---|---|---|---|\n")
+		for _, finding := range scan.Findings {
+			fmt.Fprintf(&out, "| `%s` | %s | %s | %s | %s | %s |\n",
+				finding.Package, finding.Version, finding.CVE, finding.Status, finding.Urgency, finding.FixedVersion)
+		}
+	}
+
+	return out.String()
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParseOSLibs(t *testing.T) {
+	packages, err := parseOSLibs(`libexpat1_2.7.1-2_amd64.deb MD5Sum:f679d79b2aaecfcb335274627a04960c
+libarmadillo14_1%3a14.2.3+dfsg-1+b1_amd64.deb MD5Sum:a0536f4ee4b36dc4f685f98587774fa0
+
+`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []osPackage{
+		{Name: "libexpat1", Version: "2.7.1-2", Arch: "amd64"},
+		{Name: "libarmadillo14", Version: "1:14.2.3+dfsg-1+b1", Arch: "amd64"},
+	}
+	if !slices.Equal(packages, want) {
+		t.Errorf("parseOSLibs() = %+v, want %+v", packages, want)
+	}
+
+	if _, err := parseOSLibs("libexpat1.deb"); err == nil {
+		t.Error("expected an error for an invalid package file name")
+	}
+}
+
+func TestSourcePackage(t *testing.T) {
+	tracker := securityTracker{"curl": {}, "openssl": {}}
+	tests := []struct {
+		pkg    osPackage
+		want   string
+		wantOK bool
+	}{
+		{pkg: osPackage{Name: "libssl3t64", Source: "openssl"}, want: "openssl", wantOK: true},
+		{pkg: osPackage{Name: "libgeos-c1t64", Source: "geos"}, want: "geos", wantOK: true},
+		{pkg: osPackage{Name: "curl"}, want: "curl", wantOK: true},
+		{pkg: osPackage{Name: "libssl3t64"}, want: "", wantOK: false},
+		{pkg: osPackage{Name: "libcurl3t64-gnutls"}, want: "", wantOK: false},
+	}
+
+	for _, tt := range tests {
+		got, ok := tracker.sourcePackage(tt.pkg)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("sourcePackage(%q) = %q, %v, want %q, %v", tt.pkg.Name, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestScanPackages(t *testing.T) {
+	tracker, err := parseSecurityTracker([]byte(`{
+  "expat": {
+    "CVE-2025-0001": {"releases": {"trixie": {"status": "resolved", "fixed_version": "2.7.1-2+deb13u1", "urgency": "medium"}}},
+    "CVE-2025-0002": {"releases": {"trixie": {"status": "resolved", "fixed_version": "2.7.0-1", "urgency": "high"}}},
+    "CVE-2025-0003": {"releases": {"trixie": {"status": "open", "urgency": "unimportant"}}},
+    "CVE-2025-0004": {"releases": {"trixie": {"status": "resolved", "fixed_version": "0"}}},
+    "CVE-2025-0005": {"releases": {"bookworm": {"status": "open"}}}
+  },
+  "libexpat1": {
+    "CVE-2025-9999": {"releases": {"trixie": {"status": "open"}}}
+  }
+}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	scan := &osLibsScan{Distribution: "trixie"}
+	scanPackages(tracker, scan, []osPackage{
+		{Name: "libexpat1", Version: "2.7.1-2", Source: "expat"},
+		{Name: "libproj25", Version: "9.6.0-1"},
+		{Name: "libexpat1", Version: "2.7.1-2"},
+	})
+
+	want := []vulnerabilityFinding{
+		{Package: "libexpat1", Version: "2.7.1-2", Source: "expat", CVE: "CVE-2025-0001",
+			Status: "resolved", FixedVersion: "2.7.1-2+deb13u1", Urgency: "medium"},
+		{Package: "libexpat1", Version: "2.7.1-2", Source: "expat", CVE: "CVE-2025-0003",
+			Status: "open", Urgency: "unimportant"},
+		{Package: "libexpat1", Version: "2.7.1-2", Source: "libexpat1", CVE: "CVE-2025-9999",
+			Status: "open"},
+	}
+	if scan.Packages != 3 || !slices.Equal(scan.Findings, want) {
+		t.Errorf("got %d packages and findings %+v, want 3 and %+v", scan.Packages, scan.Findings, want)
+	}
+	if !slices.Equal(scan.Unresolved, []string{"libproj25"}) {
+		t.Errorf("got unresolved packages %v, want [libproj25]", scan.Unresolved)
+	}
+}
+
+func TestFormatVulnerabilityScansUnresolved(t *testing.T) {
+	got := formatVulnerabilityScans([]*osLibsScan{{
+		Extension:    "postgis",
+		Distribution: "trixie",
+		PgMajor:      "18",
+		Origin:       "postgis/system-libs/18-trixie-os-libs.txt",
+		Packages:     3,
+		Unresolved:   []string{"libcurl3t64-gnutls", "libssl3t64"},
+	}})
+	want := "## postgis (PostgreSQL 18 on trixie)\n\n" +
+		"3 packages scanned from `postgis/system-libs/18-trixie-os-libs.txt`.\n\n" +
+		"**2 packages have an unknown source package, their vulnerabilities are unknown**: `libcurl3t64-gnutls`, `libssl3t64`.\n\n" +
+		"No known vulnerabilities in the 1 resolved packages, the unresolved ones weren't checked.\n"
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestCheckResolvedScan(t *testing.T) {
+	scan := &osLibsScan{
+		Origin:     "postgis/system-libs/18-trixie-os-libs.txt",
+		Packages:   2,
+		Unresolved: []string{"libabsl20240722", "libexpat1"},
+	}
+	if err := checkResolvedScan(scan); err == nil {
+		t.Error("expected an error when no package is resolved")
+	}
+
+	scan.Unresolved = scan.Unresolved[1:]
+	if err := checkResolvedScan(scan); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := checkResolvedScan(&osLibsScan{}); err != nil {
+		t.Errorf("unexpected error for an empty scan: %v", err)
+	}
+}
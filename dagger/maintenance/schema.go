@@ -0,0 +1,229 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsimple"
+	"github.com/zclconf/go-cty/cty"
+	ctyjson "github.com/zclconf/go-cty/cty/json"
+)
+
+// jsonSchemaDialect is the JSON Schema version of the generated schema
+const jsonSchemaDialect = "https://json-schema.org/draft/2020-12/schema"
+
+// jsonSchema is the subset of JSON Schema needed to describe metadata.hcl.
+type jsonSchema struct {
+	Schema      string   `json:"$schema,omitempty"`
+	Title       string   `json:"title,omitempty"`
+	Description string   `json:"description,omitempty"`
+	Type        string   `json:"type,omitempty"`
+	Enum        []string `json:"enum,omitempty"`
+	Pattern     string   `json:"pattern,omitempty"`
+	// Items is the schema of the elements of an array
+	Items      *jsonSchema            `json:"items,omitempty"`
+	Properties map[string]*jsonSchema `json:"properties,omitempty"`
+	Required   []string               `json:"required,omitempty"`
+	// AdditionalProperties is either false or the schema of the properties
+	// not listed in Properties
+	AdditionalProperties any `json:"additionalProperties,omitempty"`
+	// PropertyNames is the schema of the property names of an object
+	PropertyNames *jsonSchema `json:"propertyNames,omitempty"`
+}
+
+// metadataSchema returns the JSON Schema of the metadata.hcl files, generated
+// from the extensionMetadata definition.
+func metadataSchema() *jsonSchema {
+	metadata := objectSchema(reflect.TypeFor[extensionMetadata]())
+	metadata.Description = "Metadata of the extension"
+
+	return &jsonSchema{
+		Schema:      jsonSchemaDialect,
+		Title:       metadataFile,
+		Description: "Definition of a PostgreSQL extension container image",
+		Type:        "object",
+		Properties:  map[string]*jsonSchema{"metadata": metadata},
+		Required:    []string{"metadata"},
+	}
+}
+
+// objectSchema returns the schema of a struct decoded from its cty tags. The
+// attributes with an optional hcl tag aren't required.
+func objectSchema(structType reflect.Type) *jsonSchema {
+	schema := &jsonSchema{
+		Type:                 "object",
+		Properties:           make(map[string]*jsonSchema),
+		AdditionalProperties: false,
+	}
+	for i := range structType.NumField() {
+		field := structType.Field(i)
+		name, ok := field.Tag.Lookup("cty")
+		if !ok {
+			continue
+		}
+
+		var property *jsonSchema
+		if name == "versions" {
+			property = versionsSchema()
+		} else {
+			property = typeSchema(field.Type, field.Tag.Get("pattern"))
+		}
+		property.Description = field.Tag.Get("description")
+		schema.Properties[name] = property
+		if !strings.HasSuffix(field.Tag.Get("hcl"), ",optional") {
+			schema.Required = append(schema.Required, name)
+		}
+	}
+
+	return schema
+}
+
+// typeSchema returns the schema of a Go type. The pattern applies to strings,
+// including the elements of a list.
+func typeSchema(goType reflect.Type, pattern string) *jsonSchema {
+	switch goType.Kind() {
+	case reflect.Pointer:
+		return typeSchema(goType.Elem(), pattern)
+	case reflect.Bool:
+		return &jsonSchema{Type: "boolean"}
+	case reflect.String:
+		return &jsonSchema{Type: "string", Pattern: pattern}
+	case reflect.Slice:
+		return &jsonSchema{Type: "array", Items: typeSchema(goType.Elem(), pattern)}
+	case reflect.Map:
+		return &jsonSchema{Type: "object", AdditionalProperties: typeSchema(goType.Elem(), "")}
+	}
+
+	panic(fmt.Sprintf("no JSON Schema for type %s", goType))
+}
+
+// versionsSchema returns the schema of the versions map, by distribution and
+// PG major.
+func versionsSchema() *jsonSchema {
+	return &jsonSchema{
+		Type:          "object",
+		PropertyNames: &jsonSchema{Enum: SupportedDistributions},
+		AdditionalProperties: &jsonSchema{
+			Type:                 "object",
+			Description:          "Versions of the packages of a distribution, by PostgreSQL major",
+			PropertyNames:        &jsonSchema{Pattern: majorVersionRegex.String()},
+			AdditionalProperties: objectSchema(reflect.TypeFor[extensionVersion]()),
+		},
+	}
+}
+
+// validateJSONSchema returns the problems of a decoded JSON value against a
+// schema, located by their path.
+func validateJSONSchema(schema *jsonSchema, value any, path string) []string {
+	var problems []string
+	addProblem := func(format string, args ...any) {
+		problems = append(problems, path+": "+fmt.Sprintf(format, args...))
+	}
+
+	switch schema.Type {
+	case "object":
+		object, ok := value.(map[string]any)
+		if !ok {
+			addProblem("expected an object")
+			return problems
+		}
+		for _, name := range schema.Required {
+			if _, ok := object[name]; !ok {
+				addProblem("missing required attribute %s", name)
+			}
+		}
+		for _, name := range slices.Sorted(maps.Keys(object)) {
+			if schema.PropertyNames != nil {
+				problems = append(problems, validateJSONSchema(schema.PropertyNames, name, path+"."+name)...)
+			}
+			if property, ok := schema.Properties[name]; ok {
+				problems = append(problems, validateJSONSchema(property, object[name], path+"."+name)...)
+				continue
+			}
+			switch additional := schema.AdditionalProperties.(type) {
+			case bool:
+				if !additional {
+					addProblem("unknown attribute %s", name)
+				}
+			case *jsonSchema:
+				problems = append(problems, validateJSONSchema(additional, object[name], path+"."+name)...)
+			}
+		}
+		return problems
+	case "array":
+		items, ok := value.([]any)
+		if !ok {
+			addProblem("expected a list")
+			return problems
+		}
+		for i, item := range items {
+			problems = append(problems, validateJSONSchema(schema.Items, item, fmt.Sprintf("%s[%d]", path, i))...)
+		}
+		return problems
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			addProblem("expected a boolean")
+		}
+		return problems
+	}
+
+	// Strings, and property names which have no type
+	text, ok := value.(string)
+	if !ok {
+		addProblem("expected a string")
+		return problems
+	}
+	if len(schema.Enum) > 0 && !slices.Contains(schema.Enum, text) {
+		addProblem("%q is not one of %s", text, strings.Join(schema.Enum, ", "))
+	}
+	if schema.Pattern != "" && !regexp.MustCompile(schema.Pattern).MatchString(text) {
+		addProblem("%q doesn't match %s", text, schema.Pattern)
+	}
+
+	return problems
+}
+
+// decodeMetadataJSON decodes the metadata object of a metadata.hcl file as
+// its JSON equivalent, to validate it against the schema.
+func decodeMetadataJSON(data []byte) (any, error) {
+	type Config struct {
+		Metadata cty.Value `hcl:"metadata"`
+		Remain   hcl.Body  `hcl:",remain"`
+	}
+
+	var rootMeta Config
+	if err := hclsimple.Decode(metadataFile, data, nil, &rootMeta); err != nil {
+		return nil, err
+	}
+	content, err := json.Marshal(ctyjson.SimpleJSONValue{Value: rootMeta.Metadata})
+	if err != nil {
+		return nil, fmt.Errorf("while converting %s to JSON: %w", metadataFile, err)
+	}
+
+	var value any
+	if err := json.Unmarshal(content, &value); err != nil {
+		return nil, fmt.Errorf("while converting %s to JSON: %w", metadataFile, err)
+	}
+
+	return value, nil
+}
+
+// validateMetadataSchema checks the content of a metadata.hcl file against
+// the schema.
+func validateMetadataSchema(validation *extensionValidation, data []byte) error {
+	value, err := decodeMetadataJSON(data)
+	if err != nil {
+		return err
+	}
+	for _, problem := range validateJSONSchema(metadataSchema().Properties["metadata"], value, "metadata") {
+		validation.addProblem("%s", problem)
+	}
+
+	return nil
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+const schemaMetadata = `metadata = {
+  name                     = "pgvector"
+  sql_name                 = "vector"
+  image_name               = "pgvector"
+  packages                 = ["postgresql-%version%-pgvector"]
+  licenses                 = ["PostgreSQL"]
+  shared_preload_libraries = []
+  postgresql_parameters    = {}
+  extension_control_path   = []
+  dynamic_library_path     = []
+  ld_library_path          = []
+  bin_path                 = []
+  env                      = {}
+  auto_update_os_libs      = false
+  required_extensions      = []
+  create_extension         = true
+  trusted                  = true
+
+  versions = {
+    bookworm = {
+      "18" = {
+        package = "0.8.1-2.pgdg12+1"
+        sql     = "0.8.1"
+      }
+    }
+    trixie = {
+      "18" = {
+        package = "0.8.1-2.pgdg13+1"
+      }
+    }
+  }
+}
+`
+
+func TestMetadataSchema(t *testing.T) {
+	metadata := metadataSchema().Properties["metadata"]
+	if slices.Contains(metadata.Required, "trusted") || !slices.Contains(metadata.Required, "versions") {
+		t.Errorf("got required attributes %v, want versions and not trusted", metadata.Required)
+	}
+	for name, property := range metadata.Properties {
+		if property.Description == "" {
+			t.Errorf("got no description for %s", name)
+		}
+	}
+
+	versions := metadata.Properties["versions"]
+	if !slices.Equal(versions.PropertyNames.Enum, SupportedDistributions) {
+		t.Errorf("got distributions %v, want %v", versions.PropertyNames.Enum, SupportedDistributions)
+	}
+	version := versions.AdditionalProperties.(*jsonSchema).AdditionalProperties.(*jsonSchema)
+	if !slices.Equal(version.Required, []string{"package"}) {
+		t.Errorf("got required version attributes %v, want [package]", version.Required)
+	}
+	if got := metadata.Properties["packages"].Items.Pattern; got == "" {
+		t.Errorf("got no pattern for the packages")
+	}
+}
+
+func TestValidateMetadataSchema(t *testing.T) {
+	validation := &extensionValidation{Extension: "pgvector"}
+	if err := validateMetadataSchema(validation, []byte(schemaMetadata)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(validation.Problems) != 0 {
+		t.Errorf("got %v, want no problems", validation.Problems)
+	}
+}
+
+func TestValidateMetadataSchemaProblems(t *testing.T) {
+	content := strings.NewReplacer(
+		`  image_name               = "pgvector"`+"\n", "",
+		`name                     = "pgvector"`, `name                     = "PgVector"`,
+		`trusted                  = true`, `trusted                  = "yes"`,
+		`create_extension         = true`, "create_extension         = true\n  relocateable = false",
+		`sql     = "0.8.1"`, `sql     = 0.8`,
+		"    trixie = {\n      \"18\"", "    sid = {\n      \"v18\"",
+	).Replace(schemaMetadata)
+	validation := &extensionValidation{Extension: "pgvector"}
+	if err := validateMetadataSchema(validation, []byte(content)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []string{
+		"metadata: missing required attribute image_name",
+		`metadata.name: "PgVector" doesn't match ^[a-z0-9_-]+$`,
+		"metadata: unknown attribute relocateable",
+		"metadata.trusted: expected a boolean",
+		"metadata.versions.bookworm.18.sql: expected a string",
+		`metadata.versions.sid: "sid" is not one of bookworm, trixie`,
+		`metadata.versions.sid.v18: "v18" doesn't match ^[1-9][0-9]*$`,
+	}
+	if !slices.Equal(validation.Problems, want) {
+		t.Errorf("got %q, want %q", validation.Problems, want)
+	}
+}
@@ -25,6 +25,7 @@ type TestingValues struct {
 	PgImage                string                    `yaml:"pg_image"`
 	Version                string                    `yaml:"version"`
 	CreateExtension        bool                      `yaml:"create_extension"`
+	Trusted                bool                      `yaml:"trusted"`
 	Extensions             []*ExtensionConfiguration `yaml:"extensions"`
 	DatabaseConfig         *DatabaseConfig           `yaml:"database_config"`
 	DatabaseAssertStatus   map[string]any            `yaml:"database_assert_status"`
@@ -35,6 +36,9 @@ type testingExtensionInfo struct {
 	SQLName         string
 	Version         string
 	CreateExtension bool
+	// Trusted extensions are created by the owner of the database, who isn't
+	// a superuser, instead of through the Database resource
+	Trusted bool
 }
 
 type imageLocator struct {
@@ -134,7 +138,7 @@ func generateExtensionConfiguration(metadata *extensionMetadata, extensionImage
 func generateDatabaseConfig(extensionInfos []*testingExtensionInfo) *DatabaseConfig {
 	var databaseConfig DatabaseConfig
 	for _, info := range extensionInfos {
-		if !info.CreateExtension {
+		if !info.CreateExtension || info.Trusted {
 			continue
 		}
 
@@ -158,7 +162,7 @@ func generateDatabaseAssertStatus(extensionInfos []*testingExtensionInfo) map[st
 
 	var extensions []map[string]any
 	for _, info := range extensionInfos {
-		if !info.CreateExtension {
+		if !info.CreateExtension || info.Trusted {
 			continue
 		}
 		extensions = append(extensions, map[string]any{
@@ -0,0 +1,23 @@
+package main
+
+import "testing"
+
+func TestGenerateDatabaseConfigTrusted(t *testing.T) {
+	infos := []*testingExtensionInfo{
+		{SQLName: "postgis_raster", Version: "3.6.0", CreateExtension: true, Trusted: true},
+		{SQLName: "postgis", Version: "3.6.0", CreateExtension: true},
+	}
+
+	// The trusted extension is created by the check job, as a non-superuser
+	config := generateDatabaseConfig(infos)
+	want := ExtensionSpec{Ensure: "present", Name: "postgis", Version: "3.6.0"}
+	if len(config.ExtensionsSpec) != 1 || config.ExtensionsSpec[0] != want {
+		t.Errorf("got %+v, want only %+v", config.ExtensionsSpec, want)
+	}
+
+	status := generateDatabaseAssertStatus(infos)
+	extensions, ok := status["extensions"].([]map[string]any)
+	if !ok || len(extensions) != 1 || extensions[0]["name"] != "postgis" {
+		t.Errorf("got status extensions %v, want only postgis", status["extensions"])
+	}
+}
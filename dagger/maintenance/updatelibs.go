@@ -2,61 +2,251 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"maps"
 	"path"
-	"regexp"
-	"strings"
+	"slices"
+	"sync"
+
+	"golang.org/x/sync/errgroup"
 
 	"dagger/maintenance/internal/dagger"
 )
 
-// libsRegex matches library dependencies from apt-get output
-// Format: 'url' library-name size [MD5Sum:checksum]
-var libsRegex = regexp.MustCompile(`(?m)^\S+\s+(lib\S+\.deb)\s+\d+\s*(MD5Sum:\S+)?\s*$`)
+// systemLibsDir is the directory of an extension holding its OS libraries files
+const systemLibsDir = "system-libs"
+
+// getOSLibsTargets returns the directories of the extensions whose OS
+// libraries are updated for the given target, either an extension or "all".
+func getOSLibsTargets(ctx context.Context, source *dagger.Directory, target string) ([]string, error) {
+	extDir := source
+	if target != "all" {
+		extDir = source.Filter(dagger.DirectoryFilterOpts{
+			Include: []string{path.Join(target, "**")},
+		})
+		hasMetadataFile, err := extDir.Exists(ctx, path.Join(target, metadataFile))
+		if err != nil {
+			return nil, err
+		}
+		if !hasMetadataFile {
+			return nil, fmt.Errorf("not a valid target, metadata.hcl file is missing. Target: %s", target)
+		}
+	}
+
+	targetExtensions, err := getExtensions(ctx, extDir, WithOSLibsFilter())
+	if err != nil {
+		return nil, err
+	}
+	if len(targetExtensions) == 0 && target != "all" {
+		return nil, fmt.Errorf("the target %q does not require OS Libs update", target)
+	}
+
+	return slices.Sorted(maps.Keys(targetExtensions)), nil
+}
+
+// osLibsJob is the resolution of the OS libraries of an extension for a
+// distribution and PG major.
+type osLibsJob struct {
+	Dir      string
+	Metadata *extensionMetadata
+	Combo    buildCombo
+	Lock     *osLibsLock
+	// Skipped is osLibsUnpackaged when the packages aren't in the pgdg
+	// repository yet, in which case Lock is nil
+	Skipped string
+	// SkipError is the resolution error of a skipped job
+	SkipError error
+}
+
+// settle records the outcome of the resolution of a job, returning the error
+// failing it. A combination whose packages aren't in the pgdg repository yet
+// is skipped. A pinned version missing from it is a stale pin in
+// metadata.hcl, failing the resolution like any other error.
+func (j *osLibsJob) settle(lock *osLibsLock, err error) error {
+	switch {
+	case err == nil:
+		j.Lock = lock
+		return nil
+	case errors.Is(err, errOSLibsVersionNotFound):
+		return fmt.Errorf("%w, the version pinned in %s is stale", err, path.Join(j.Dir, metadataFile))
+	case errors.Is(err, errOSLibsPackageNotFound):
+		j.Skipped, j.SkipError = osLibsUnpackaged, err
+		return nil
+	}
+
+	return err
+}
+
+// osLibsResolver resolves the OS libraries of the extensions, sharing one
+// apt-updated base container per distribution, PG major and platform.
+type osLibsResolver struct {
+	mu         sync.Mutex
+	containers map[string]*dagger.Container
+}
+
+// newOSLibsResolver creates a resolver without any base container.
+func newOSLibsResolver() *osLibsResolver {
+	return &osLibsResolver{containers: make(map[string]*dagger.Container)}
+}
+
+// baseContainer returns the apt-updated PostgreSQL base image for a
+// distribution and PG major, running under the given platform.
+func (r *osLibsResolver) baseContainer(distribution string, majorVersion string, platform string) *dagger.Container {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := fmt.Sprintf("%s-%s-%s", majorVersion, distribution, platform)
+	if container, ok := r.containers[key]; ok {
+		return container
+	}
+
+	postgresBaseImage := fmt.Sprintf("ghcr.io/cloudnative-pg/postgresql:%s-minimal-%s", majorVersion, distribution)
+	container := dag.Container(dagger.ContainerOpts{Platform: dagger.Platform(platform)}).
+		From(postgresBaseImage).
+		WithUser("root").
+		WithExec([]string{"apt-get", "update"})
+	r.containers[key] = container
+
+	return container
+}
+
+// resolveAll resolves the jobs concurrently, running at most concurrency of
+// them at once. Every job is attempted, and the failures are joined. The jobs
+// whose packages aren't in the pgdg repository yet are marked as skipped
+// instead.
+func (r *osLibsResolver) resolveAll(ctx context.Context, jobs []*osLibsJob, concurrency int) error {
+	if concurrency < 1 {
+		return fmt.Errorf("invalid concurrency %d, must be at least 1", concurrency)
+	}
+
+	var group errgroup.Group
+	group.SetLimit(concurrency)
+	errs := make([]error, len(jobs))
+	for i, job := range jobs {
+		group.Go(func() error {
+			lock, err := r.resolve(ctx, job.Metadata, job.Combo.Distribution, job.Combo.MajorVersion)
+			errs[i] = job.settle(lock, err)
+			return nil
+		})
+	}
+	_ = group.Wait()
+
+	return errors.Join(errs...)
+}
 
-func updateOSLibsOnTarget(
+// resolve resolves the dependencies of the packages of an extension,
+// pinned to the package version declared for the distribution and PG major,
+// on each platform the images are built for.
+func (r *osLibsResolver) resolve(
 	ctx context.Context,
-	target string,
+	metadata *extensionMetadata,
 	distribution string,
 	majorVersion string,
-) (*dagger.File, error) {
-	postgresBaseImage := fmt.Sprintf("ghcr.io/cloudnative-pg/postgresql:%s-minimal-%s", majorVersion, distribution)
-	packageName := fmt.Sprintf("postgresql-%s-%s", majorVersion, target)
+) (*osLibsLock, error) {
+	target := metadata.Name
 
-	out, err := dag.Container().
-		From(postgresBaseImage).
-		WithUser("root").
-		WithExec([]string{"apt-get", "update"}).
-		WithExec([]string{"bash",
-			"-c",
-			"apt-get install -qq --print-uris --no-install-recommends " + packageName,
-		}).Stdout(ctx)
+	version, ok := metadata.Versions[distribution][majorVersion]
+	if !ok {
+		return nil, fmt.Errorf("no version declared in metadata for extension %s (PostgreSQL %s on %s)",
+			target, majorVersion, distribution)
+	}
+	packages, err := resolvePackages(metadata, majorVersion)
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch OS libs for extension %s (PostgreSQL %s on %s): %w",
-			target, majorVersion, distribution, err)
+		return nil, err
+	}
+	pinnedPackages := make([]string, 0, len(packages))
+	for _, pkg := range packages {
+		pinnedPackages = append(pinnedPackages, pkg+"="+version.Package)
 	}
 
-	matches := libsRegex.FindAllStringSubmatch(out, -1)
-	if len(matches) == 0 {
-		return nil, fmt.Errorf("no library dependencies found for extension %s (PostgreSQL %s on %s): apt-get may have failed or package has no lib dependencies",
-			target, majorVersion, distribution)
+	lock := &osLibsLock{
+		Version:      osLibsLockVersion,
+		Extension:    target,
+		Distribution: distribution,
+		PgMajor:      majorVersion,
+		Platforms:    make(map[string][]osLibsLockPackage, len(requiredPlatforms)),
+	}
+	for _, platform := range requiredPlatforms {
+		lockPackages, err := r.resolveOnPlatform(ctx, distribution, majorVersion, platform, pinnedPackages)
+		if err != nil {
+			return nil, fmt.Errorf("extension %s (PostgreSQL %s on %s, %s): %w",
+				target, majorVersion, distribution, platform, err)
+		}
+		lock.Platforms[platform] = lockPackages
 	}
 
-	var result string
-	for _, m := range matches {
-		line := strings.Join(m[1:], " ")
-		result += strings.TrimSpace(line) + "\n"
+	return lock, nil
+}
+
+// osLibsFiles returns the OS libraries lockfile and the text file derived from it.
+func osLibsFiles(lock *osLibsLock) ([]*dagger.File, error) {
+	data, err := lock.marshal()
+	if err != nil {
+		return nil, err
 	}
 
-	if result == "" {
-		return nil, fmt.Errorf("parsed empty content for extension %s (PostgreSQL %s on %s): regex matched but extracted no data",
-			target, majorVersion, distribution)
+	// The text file is empty when the dependencies are all shipped by the base image
+	text := formatOSLibsText(lock.Platforms[osLibsTextPlatform])
+
+	return []*dagger.File{
+		dag.File(osLibsLockFileName(lock.PgMajor, lock.Distribution), string(data)),
+		dag.File(osLibsTextFileName(lock.PgMajor, lock.Distribution), text),
+	}, nil
+}
+
+// resolveOnPlatform resolves the dependencies of the pinned packages in a
+// PostgreSQL base image running under the given platform, so that the
+// dependency sets and versions of each architecture are recorded.
+func (r *osLibsResolver) resolveOnPlatform(
+	ctx context.Context,
+	distribution string,
+	majorVersion string,
+	platform string,
+	pinnedPackages []string,
+) ([]osLibsLockPackage, error) {
+	container := r.baseContainer(distribution, majorVersion, platform)
+
+	// Expect any exit code, so that apt-get errors are classified from its output
+	printURIs := container.
+		WithExec(append([]string{
+			"apt-get", "install", "-qq", "--print-uris", "--no-install-recommends",
+		}, pinnedPackages...), dagger.ContainerWithExecOpts{Expect: dagger.ReturnTypeAny})
+	exitCode, err := printURIs.ExitCode(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch OS libs: %w", err)
+	}
+	stdout, err := printURIs.Stdout(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch OS libs: %w", err)
+	}
+	stderr, err := printURIs.Stderr(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch OS libs: %w", err)
 	}
 
-	fileName := fmt.Sprintf("%s-%s-os-libs.txt", majorVersion, distribution)
-	file := dag.File(fileName, result)
+	requested, err := parsePrintURIs(stdout, stderr, exitCode)
+	if err != nil {
+		return nil, err
+	}
+	if len(requested) == 0 {
+		return []osLibsLockPackage{}, nil
+	}
+
+	showArgs := []string{"apt-cache", "show"}
+	for _, pkg := range requested {
+		showArgs = append(showArgs, pkg.Name+"="+pkg.Version)
+	}
+	out, err := container.WithExec(showArgs).Stdout(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to show OS libs: %w", err)
+	}
+	stanzas, err := parseAptCacheShow(out)
+	if err != nil {
+		return nil, err
+	}
 
-	return file, nil
+	return selectLockPackages(requested, stanzas)
 }
 
 type extensionsOptions struct {
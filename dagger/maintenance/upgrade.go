@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/crane"
+)
+
+// getLatestPublishedImage returns the most recently published extension image
+// for a given distribution and PG major, or an empty string if none was published.
+func getLatestPublishedImage(metadata *extensionMetadata, distribution string, pgMajor int) (string, error) {
+	imageName := fmt.Sprintf("ghcr.io/cloudnative-pg/%s", metadata.ImageName)
+	tags, err := crane.ListTags(imageName)
+	if err != nil {
+		return "", fmt.Errorf("while listing tags for image %s: %w", imageName, err)
+	}
+
+	latestTag := latestTimestampedTag(tags, "", distribution, pgMajor)
+	if latestTag == "" {
+		return "", nil
+	}
+
+	return fmt.Sprintf("%s:%s", imageName, latestTag), nil
+}
+
+// upgradeScripts returns the update scripts (<sql_name>--<from>--<to>.sql)
+// shipped by an extension image, as a map from source to target versions.
+func upgradeScripts(fsys *imageFS, metadata *extensionMetadata) map[string][]string {
+	var names []string
+	for _, dir := range controlDirectories(metadata) {
+		names = append(names, fsys.glob(path.Join(dir, metadata.SQLName+"--*--*.sql"))...)
+	}
+
+	return parseUpgradeScripts(names, metadata.SQLName)
+}
+
+// parseUpgradeScripts returns the update scripts of an extension among a list
+// of file names, as a map from source to target versions.
+func parseUpgradeScripts(names []string, sqlName string) map[string][]string {
+	scripts := make(map[string][]string)
+	prefix := sqlName + "--"
+	for _, name := range names {
+		base := path.Base(name)
+		if !strings.HasPrefix(base, prefix) || !strings.HasSuffix(base, ".sql") {
+			continue
+		}
+		versions := strings.TrimSuffix(strings.TrimPrefix(base, prefix), ".sql")
+		from, to, ok := strings.Cut(versions, "--")
+		if !ok || from == "" || to == "" || strings.Contains(to, "--") {
+			continue
+		}
+		if !slices.Contains(scripts[from], to) {
+			scripts[from] = append(scripts[from], to)
+		}
+	}
+
+	return scripts
+}
+
+// findUpgradePath returns the shortest chain of versions, from the source to
+// the target version included, which can be followed through the update
+// scripts. It returns nil if the target version can't be reached.
+func findUpgradePath(scripts map[string][]string, from, to string) []string {
+	previous := map[string]string{from: ""}
+	queue := []string{from}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+
+		if current == to {
+			chain := []string{current}
+			for version := previous[current]; version != ""; version = previous[version] {
+				chain = append(chain, version)
+			}
+			slices.Reverse(chain)
+			return chain
+		}
+
+		next := slices.Clone(scripts[current])
+		slices.Sort(next)
+		for _, version := range next {
+			if _, visited := previous[version]; !visited {
+				previous[version] = current
+				queue = append(queue, version)
+			}
+		}
+	}
+
+	return nil
+}
+
+// verifyUpgradePath checks that an image file system ships a chain of update
+// scripts from the published SQL version to the new one.
+func verifyUpgradePath(verification *imageVerification, fsys *imageFS, metadata *extensionMetadata, from, to string) {
+	if findUpgradePath(upgradeScripts(fsys, metadata), from, to) == nil {
+		verification.addProblem("no %s--*--*.sql update script path from version %s to %s",
+			metadata.SQLName, from, to)
+	}
+}
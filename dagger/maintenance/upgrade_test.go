@@ -0,0 +1,63 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestUpgradeScripts(t *testing.T) {
+	fsys := newTestImageFS(t, map[string]string{
+		"share/extension/vector.control":            "",
+		"share/extension/vector--0.8.0.sql":         "",
+		"share/extension/vector--0.7.4--0.8.0.sql":  "",
+		"share/extension/vector--0.8.0--0.8.1.sql":  "",
+		"share/extension/other--0.1.0--0.2.0.sql":   "",
+		"share/extension/vector--0.8.1--0.8.2.sql":  "",
+		"share/extension/vector--0.8.1--0.8.2.sql~": "",
+	})
+	metadata := &extensionMetadata{SQLName: "vector"}
+
+	got := upgradeScripts(fsys, metadata)
+	want := map[string][]string{
+		"0.7.4": {"0.8.0"},
+		"0.8.0": {"0.8.1"},
+		"0.8.1": {"0.8.2"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("upgradeScripts() = %v, expected %v", got, want)
+	}
+	for from, to := range want {
+		if !slices.Equal(got[from], to) {
+			t.Errorf("upgradeScripts()[%q] = %v, expected %v", from, got[from], to)
+		}
+	}
+}
+
+func TestFindUpgradePath(t *testing.T) {
+	scripts := map[string][]string{
+		"1.0": {"1.1", "2.0"},
+		"1.1": {"1.2"},
+		"1.2": {"2.0"},
+		"2.0": {"2.1"},
+		"3.0": {"1.0"},
+	}
+
+	tests := []struct {
+		name     string
+		from, to string
+		want     []string
+	}{
+		{name: "direct", from: "1.1", to: "1.2", want: []string{"1.1", "1.2"}},
+		{name: "shortest chain", from: "1.0", to: "2.1", want: []string{"1.0", "2.0", "2.1"}},
+		{name: "unreachable", from: "1.0", to: "3.0", want: nil},
+		{name: "unknown version", from: "0.9", to: "1.0", want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := findUpgradePath(scripts, tt.from, tt.to); !slices.Equal(got, tt.want) {
+				t.Errorf("findUpgradePath() = %v, expected %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// pgdgSuffixes maps the supported distributions to the suffix of the versions
+// of the pgdg packages built for them
+var pgdgSuffixes = map[string]string{
+	"bookworm": "pgdg12",
+	"trixie":   "pgdg13",
+}
+
+// pgdgSuffixRegex matches the pgdg suffix of a package version (e.g. "0.8.1-2.pgdg13+1")
+var pgdgSuffixRegex = regexp.MustCompile(`\.(pgdg\d+)`)
+
+// renovateAttributes are the attributes of a version entry kept up to date by
+// renovate, which need an annotation
+var renovateAttributes = []string{"package", "sql"}
+
+// extensionValidation collects the problems found while validating the
+// metadata.hcl file of an extension.
+type extensionValidation struct {
+	Extension string
+	Problems  []string
+}
+
+// addProblem records a problem found in the metadata.hcl file.
+func (v *extensionValidation) addProblem(format string, args ...any) {
+	v.Problems = append(v.Problems, fmt.Sprintf(format, args...))
+}
+
+// validateRenovateAnnotations checks the renovate annotations of the package
+// and sql versions: the suite must be the pgdg suite of the distribution and
+// the depName a package of the PG major. It also checks that the package
+// versions were built for their distribution.
+func validateRenovateAnnotations(validation *extensionValidation, document *metadataDocument) error {
+	entries, err := document.versionEntries()
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		where := fmt.Sprintf("PostgreSQL %s on %s", entry.MajorVersion, entry.Distribution)
+		for _, name := range renovateAttributes {
+			if _, ok := entry.Attributes[name]; !ok {
+				continue
+			}
+			annotation := entry.Annotations[name]
+			if annotation == nil {
+				validation.addProblem("%s: no renovate annotation for %s", where, name)
+				continue
+			}
+			if suite := entry.Distribution + "-pgdg"; annotation.Suite != suite {
+				validation.addProblem("%s: renovate annotation of %s has suite %q, expected %q",
+					where, name, annotation.Suite, suite)
+			}
+			if prefix := fmt.Sprintf("postgresql-%s-", entry.MajorVersion); !strings.HasPrefix(annotation.DepName, prefix) {
+				validation.addProblem("%s: renovate annotation of %s has depName %q, expected a %s* package",
+					where, name, annotation.DepName, prefix)
+			}
+		}
+
+		packageVersion, ok := document.attributeValue(entry, "package")
+		if !ok {
+			continue
+		}
+		suffix, known := pgdgSuffixes[entry.Distribution]
+		if !known {
+			validation.addProblem("%s: unknown pgdg suffix for distribution %s", where, entry.Distribution)
+			continue
+		}
+		matches := pgdgSuffixRegex.FindStringSubmatch(packageVersion)
+		if matches == nil || matches[1] != suffix {
+			validation.addProblem("%s: package version %s isn't a %s build", where, packageVersion, suffix)
+		}
+	}
+
+	return nil
+}
+
+// formatValidations renders the outcome of the validation of every extension.
+func formatValidations(validations []*extensionValidation) string {
+	var report strings.Builder
+	for _, validation := range validations {
+		if len(validation.Problems) == 0 {
+			fmt.Fprintf(&report, "%s: OK\n", validation.Extension)
+			continue
+		}
+		fmt.Fprintf(&report, "%s: %d problem(s)\n", validation.Extension, len(validation.Problems))
+		for _, problem := range validation.Problems {
+			fmt.Fprintf(&report, "  - %s\n", problem)
+		}
+	}
+
+	return report.String()
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestValidateRenovateAnnotations(t *testing.T) {
+	document, err := parseMetadataDocument([]byte(editableMetadata))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	validation := &extensionValidation{Extension: "pgvector"}
+	if err := validateRenovateAnnotations(validation, document); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(validation.Problems) != 0 {
+		t.Errorf("got %v, want no problems", validation.Problems)
+	}
+}
+
+func TestValidateRenovateAnnotationsProblems(t *testing.T) {
+	content := strings.NewReplacer(
+		"suite=bookworm-pgdg depName=postgresql-18-pgvector\n", "suite=trixie-pgdg depName=postgresql-18-pgvector\n",
+		"depName=postgresql-17-pgvector", "depName=postgresql-18-pgvector",
+		`"0.8.1-2.pgdg13+1"`, `"0.8.1-2.pgdg12+1"`,
+	).Replace(editableMetadata)
+	document, err := parseMetadataDocument([]byte(content))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	validation := &extensionValidation{Extension: "pgvector"}
+	if err := validateRenovateAnnotations(validation, document); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []string{
+		`PostgreSQL 18 on bookworm: renovate annotation of package has suite "trixie-pgdg", expected "bookworm-pgdg"`,
+		`PostgreSQL 17 on trixie: renovate annotation of package has depName "postgresql-18-pgvector", expected a postgresql-17-* package`,
+		`PostgreSQL 18 on trixie: package version 0.8.1-2.pgdg12+1 isn't a pgdg13 build`,
+	}
+	if !slices.Equal(validation.Problems, want) {
+		t.Errorf("got %q, want %q", validation.Problems, want)
+	}
+}
+
+func TestValidateRenovateAnnotationsMissing(t *testing.T) {
+	content := strings.Replace(editableMetadata,
+		"        // renovate: suite=bookworm-pgdg depName=postgresql-18-pgvector extractVersion=^(?<version>\\d+\\.\\d+\\.\\d+)\n",
+		"", 1)
+	document, err := parseMetadataDocument([]byte(content))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	validation := &extensionValidation{Extension: "pgvector"}
+	if err := validateRenovateAnnotations(validation, document); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []string{"PostgreSQL 18 on bookworm: no renovate annotation for sql"}
+	if !slices.Equal(validation.Problems, want) {
+		t.Errorf("got %q, want %q", validation.Problems, want)
+	}
+}
+
+func TestFormatValidations(t *testing.T) {
+	got := formatValidations([]*extensionValidation{
+		{Extension: "pgaudit"},
+		{Extension: "pgvector", Problems: []string{"PostgreSQL 18 on trixie: no renovate annotation for sql"}},
+	})
+	want := "pgaudit: OK\n" +
+		"pgvector: 1 problem(s)\n" +
+		"  - PostgreSQL 18 on trixie: no renovate annotation for sql\n"
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
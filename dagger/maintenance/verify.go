@@ -0,0 +1,255 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+const (
+	licensesDir = "licenses"
+	systemDir   = "system"
+)
+
+// imageVerification collects the problems found while verifying a single
+// platform image of an extension, and the notes reported along with them.
+type imageVerification struct {
+	Platform string
+	Problems []string
+	Notes    []string
+}
+
+// addProblem records a problem found in the image.
+func (v *imageVerification) addProblem(format string, args ...any) {
+	v.Problems = append(v.Problems, fmt.Sprintf(format, args...))
+}
+
+// addNote records a finding about the image which isn't a problem.
+func (v *imageVerification) addNote(format string, args ...any) {
+	v.Notes = append(v.Notes, fmt.Sprintf(format, args...))
+}
+
+// controlDirectories returns the directories, relative to the image root,
+// where PostgreSQL looks for the extension's control files.
+func controlDirectories(metadata *extensionMetadata) []string {
+	dirs := []string{"share"}
+	if len(metadata.ExtensionControlPath) > 0 {
+		dirs = metadata.ExtensionControlPath
+	}
+
+	controlDirs := make([]string, 0, len(dirs))
+	for _, dir := range dirs {
+		controlDirs = append(controlDirs, path.Join(cleanImagePath(dir), "extension"))
+	}
+
+	return controlDirs
+}
+
+// libraryDirectories returns the directories, relative to the image root,
+// where PostgreSQL looks for the extension's shared objects.
+func libraryDirectories(metadata *extensionMetadata) []string {
+	dirs := []string{"lib"}
+	if len(metadata.DynamicLibraryPath) > 0 {
+		dirs = metadata.DynamicLibraryPath
+	}
+
+	libDirs := make([]string, 0, len(dirs))
+	for _, dir := range dirs {
+		libDirs = append(libDirs, cleanImagePath(dir))
+	}
+
+	return libDirs
+}
+
+// isControlFile reports whether the file name refers to an extension control file.
+func isControlFile(name string) bool {
+	return path.Ext(name) == ".control"
+}
+
+// findControlFile returns the control file of the extension with the given SQL name,
+// or nil if it can't be found in any of the control directories.
+func findControlFile(fsys *imageFS, metadata *extensionMetadata, sqlName string) *imageFile {
+	for _, dir := range controlDirectories(metadata) {
+		if file := fsys.file(path.Join(dir, sqlName+".control")); file != nil {
+			return file
+		}
+	}
+
+	return nil
+}
+
+// verifyImageFS checks the file system of an extension image against the
+// extension's metadata, given the SQL version the image is expected to ship.
+func verifyImageFS(verification *imageVerification, fsys *imageFS, metadata *extensionMetadata, sqlVersion string) {
+	var sharedObjects []string
+	for _, dir := range libraryDirectories(metadata) {
+		sharedObjects = append(sharedObjects, fsys.glob(path.Join(dir, "*.so"))...)
+	}
+	if len(sharedObjects) == 0 {
+		verification.addProblem("no shared objects found in %s",
+			strings.Join(libraryDirectories(metadata), ", "))
+	}
+
+	if metadata.CreateExtension {
+		verifyControlFile(verification, fsys, metadata, sqlVersion)
+	}
+
+	for _, dir := range metadata.LdLibraryPath {
+		if !fsys.isDir(dir) {
+			verification.addProblem("directory %q listed in ld_library_path is missing", dir)
+		}
+	}
+	if fsys.exists(systemDir) && !slices.ContainsFunc(metadata.LdLibraryPath, func(dir string) bool {
+		return cleanImagePath(dir) == systemDir
+	}) {
+		verification.addProblem("directory %q is present but not listed in ld_library_path", systemDir)
+	}
+
+	for _, dir := range metadata.BinPath {
+		if !fsys.isDir(dir) {
+			verification.addProblem("directory %q listed in bin_path is missing", dir)
+		}
+	}
+
+	if !fsys.isDir(licensesDir) {
+		verification.addProblem("directory %q is missing", licensesDir)
+	}
+}
+
+// readExtensionControl parses the control file of an extension, returning
+// nil if the image doesn't ship it.
+func readExtensionControl(fsys *imageFS, metadata *extensionMetadata) (*ExtensionControl, error) {
+	controlFile := findControlFile(fsys, metadata, metadata.SQLName)
+	if controlFile == nil {
+		return nil, nil
+	}
+
+	params, err := parseControlFile(string(controlFile.Data))
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse control file %s: %w", controlFile.Name, err)
+	}
+
+	return newExtensionControl(metadata.SQLName, params)
+}
+
+// getImageControl returns the properties declared in the control file of an
+// extension image.
+func getImageControl(imageRef string, metadata *extensionMetadata, opts ...remote.Option) (*ExtensionControl, error) {
+	images, err := getPlatformImages(imageRef, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if len(images) == 0 {
+		return nil, fmt.Errorf("extension image %s doesn't hold any platform image", imageRef)
+	}
+
+	// Control files are architecture independent, the first platform is enough
+	fsys, err := readImageFS(images[0].Image, isControlFile)
+	if err != nil {
+		return nil, fmt.Errorf("extension image %s (%s): %w", imageRef, images[0].Platform, err)
+	}
+
+	control, err := readExtensionControl(fsys, metadata)
+	if err != nil {
+		return nil, fmt.Errorf("extension image %s: %w", imageRef, err)
+	}
+	if control == nil {
+		return nil, fmt.Errorf("extension image %s doesn't ship the %s.control file", imageRef, metadata.SQLName)
+	}
+
+	return control, nil
+}
+
+// bundledExtensions returns the SQL names of the extensions whose control
+// file is shipped in an image.
+func bundledExtensions(fsys *imageFS, metadata *extensionMetadata) []string {
+	var sqlNames []string
+	for _, dir := range controlDirectories(metadata) {
+		for _, name := range fsys.glob(path.Join(dir, "*.control")) {
+			sqlNames = append(sqlNames, strings.TrimSuffix(path.Base(name), ".control"))
+		}
+	}
+
+	return sqlNames
+}
+
+// verifyControlProperties compares the requires, trusted, superuser and
+// relocatable properties of the control file with the metadata. The
+// builtinSQLNames are the extensions shipped with PostgreSQL in the base image.
+// A missing control file is reported by verifyControlFile.
+func verifyControlProperties(
+	verification *imageVerification,
+	fsys *imageFS,
+	metadata *extensionMetadata,
+	requiredSQLNames []string,
+	builtinSQLNames []string,
+) {
+	control, err := readExtensionControl(fsys, metadata)
+	if err != nil {
+		verification.addProblem("%v", err)
+		return
+	}
+	if control == nil {
+		return
+	}
+
+	verifyExtensionControl(verification, control, metadata, requiredSQLNames, bundledExtensions(fsys, metadata),
+		builtinSQLNames)
+}
+
+// verifyControlFile checks that the control file of the extension exists and
+// that its default version matches the expected SQL version.
+func verifyControlFile(verification *imageVerification, fsys *imageFS, metadata *extensionMetadata, sqlVersion string) {
+	controlFile := findControlFile(fsys, metadata, metadata.SQLName)
+	if controlFile == nil {
+		verification.addProblem("control file %s.control not found in %s",
+			metadata.SQLName, strings.Join(controlDirectories(metadata), ", "))
+		return
+	}
+
+	params, err := parseControlFile(string(controlFile.Data))
+	if err != nil {
+		verification.addProblem("cannot parse control file %s: %v", controlFile.Name, err)
+		return
+	}
+
+	defaultVersion := params["default_version"]
+	switch {
+	case defaultVersion == "":
+		verification.addProblem("control file %s doesn't declare a default_version", controlFile.Name)
+	case sqlVersion != "" && defaultVersion != sqlVersion:
+		verification.addProblem("control file %s declares default_version %q, expected %q",
+			controlFile.Name, defaultVersion, sqlVersion)
+	}
+}
+
+// formatVerifications renders the outcome of the verification of every platform.
+func formatVerifications(imageRef string, verifications []*imageVerification) string {
+	var report strings.Builder
+	fmt.Fprintf(&report, "Verification of %s\n", imageRef)
+	for _, verification := range verifications {
+		if len(verification.Problems) == 0 {
+			fmt.Fprintf(&report, "  %s: OK\n", verification.Platform)
+		} else {
+			fmt.Fprintf(&report, "  %s: %d problem(s)\n", verification.Platform, len(verification.Problems))
+		}
+		for _, problem := range verification.Problems {
+			fmt.Fprintf(&report, "    - %s\n", problem)
+		}
+		for _, note := range verification.Notes {
+			fmt.Fprintf(&report, "    note: %s\n", note)
+		}
+	}
+
+	return report.String()
+}
+
+// hasProblems reports whether any of the verifications found a problem.
+func hasProblems(verifications []*imageVerification) bool {
+	return slices.ContainsFunc(verifications, func(verification *imageVerification) bool {
+		return len(verification.Problems) > 0
+	})
+}
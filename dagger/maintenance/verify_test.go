@@ -0,0 +1,185 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/crane"
+)
+
+// newTestImageFS builds a single layer image with the given files and reads
+// back its file system, loading the content of every file.
+func newTestImageFS(t *testing.T, files map[string]string) *imageFS {
+	t.Helper()
+
+	filemap := make(map[string][]byte, len(files))
+	for name, content := range files {
+		filemap[name] = []byte(content)
+	}
+	img, err := crane.Image(filemap)
+	if err != nil {
+		t.Fatalf("while building image: %v", err)
+	}
+
+	fsys, err := readImageFS(img, func(string) bool { return true })
+	if err != nil {
+		t.Fatalf("while reading image: %v", err)
+	}
+
+	return fsys
+}
+
+func TestImageFS(t *testing.T) {
+	fsys := newTestImageFS(t, map[string]string{
+		"lib/vector.so":                              "ELF",
+		"share/extension/vector.control":             "default_version = '0.8.6'",
+		"share/extension/vector--0.8.6.sql":          "",
+		"/licenses/postgresql-18-pgvector/copyright": "",
+	})
+
+	if !fsys.isDir("lib") || !fsys.isDir("/licenses/postgresql-18-pgvector") {
+		t.Error("expected parent directories to be present")
+	}
+	if fsys.isDir("lib/vector.so") || !fsys.exists("lib/vector.so") {
+		t.Error("expected lib/vector.so to be a regular file")
+	}
+	if fsys.exists("system") {
+		t.Error("unexpected system directory")
+	}
+
+	if got := fsys.glob("share/extension/vector*"); !slices.Equal(got, []string{
+		"share/extension/vector--0.8.6.sql",
+		"share/extension/vector.control",
+	}) {
+		t.Errorf("glob: got %v", got)
+	}
+
+	if got := string(fsys.file("share/extension/vector.control").Data); got != "default_version = '0.8.6'" {
+		t.Errorf("control file content: got %q", got)
+	}
+
+	var names []string
+	for _, file := range fsys.walk("licenses") {
+		names = append(names, file.Name)
+	}
+	if !slices.Equal(names, []string{"licenses/postgresql-18-pgvector", "licenses/postgresql-18-pgvector/copyright"}) {
+		t.Errorf("walk: got %v", names)
+	}
+}
+
+func TestVerifyImageFS(t *testing.T) {
+	pgvector := &extensionMetadata{
+		Name:            "pgvector",
+		SQLName:         "vector",
+		CreateExtension: true,
+	}
+	postgis := &extensionMetadata{
+		Name:            "postgis",
+		SQLName:         "postgis",
+		CreateExtension: true,
+		LdLibraryPath:   []string{"system"},
+	}
+	walg := &extensionMetadata{
+		Name:    "wal-g",
+		SQLName: "walg",
+		BinPath: []string{"bin"},
+	}
+
+	tests := []struct {
+		name         string
+		metadata     *extensionMetadata
+		sqlVersion   string
+		files        map[string]string
+		wantProblems []string
+	}{
+		{
+			name:       "valid image",
+			metadata:   pgvector,
+			sqlVersion: "0.8.6",
+			files: map[string]string{
+				"lib/vector.so":                  "",
+				"share/extension/vector.control": "default_version = '0.8.6'",
+				"licenses/pgvector/copyright":    "",
+			},
+		},
+		{
+			name:       "missing shared objects and licenses",
+			metadata:   pgvector,
+			sqlVersion: "0.8.6",
+			files: map[string]string{
+				"share/extension/vector.control": "default_version = '0.8.6'",
+			},
+			wantProblems: []string{"no shared objects", `"licenses" is missing`},
+		},
+		{
+			name:       "missing control file",
+			metadata:   pgvector,
+			sqlVersion: "0.8.6",
+			files: map[string]string{
+				"lib/vector.so":               "",
+				"licenses/pgvector/copyright": "",
+			},
+			wantProblems: []string{"control file vector.control not found"},
+		},
+		{
+			name:       "mismatching default version",
+			metadata:   pgvector,
+			sqlVersion: "0.8.6",
+			files: map[string]string{
+				"lib/vector.so":                  "",
+				"share/extension/vector.control": "default_version = '0.8.5'",
+				"licenses/pgvector/copyright":    "",
+			},
+			wantProblems: []string{`declares default_version "0.8.5", expected "0.8.6"`},
+		},
+		{
+			name:       "unexpected system directory",
+			metadata:   pgvector,
+			sqlVersion: "0.8.6",
+			files: map[string]string{
+				"lib/vector.so":                  "",
+				"share/extension/vector.control": "default_version = '0.8.6'",
+				"system/libfoo.so.1":             "",
+				"licenses/pgvector/copyright":    "",
+			},
+			wantProblems: []string{`"system" is present but not listed in ld_library_path`},
+		},
+		{
+			name:       "missing system directory",
+			metadata:   postgis,
+			sqlVersion: "3.6.4",
+			files: map[string]string{
+				"lib/postgis-3.so":                "",
+				"share/extension/postgis.control": "default_version = '3.6.4'",
+				"licenses/postgis/copyright":      "",
+			},
+			wantProblems: []string{`"system" listed in ld_library_path is missing`},
+		},
+		{
+			name:     "missing bin directory, no control file required",
+			metadata: walg,
+			files: map[string]string{
+				"lib/walg.so":             "",
+				"licenses/walg/copyright": "",
+			},
+			wantProblems: []string{`"bin" listed in bin_path is missing`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			verification := &imageVerification{Platform: "linux/amd64"}
+			verifyImageFS(verification, newTestImageFS(t, tt.files), tt.metadata, tt.sqlVersion)
+
+			if len(verification.Problems) != len(tt.wantProblems) {
+				t.Fatalf("got problems %q, want %d problem(s)", verification.Problems, len(tt.wantProblems))
+			}
+			for i, want := range tt.wantProblems {
+				if !strings.Contains(verification.Problems[i], want) {
+					t.Errorf("problem %d: got %q, want it to contain %q", i, verification.Problems[i], want)
+				}
+			}
+		})
+	}
+}
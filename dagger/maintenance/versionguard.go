@@ -0,0 +1,203 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"dagger/maintenance/internal/dagger"
+)
+
+// versionChange is a change of the versions declared in metadata.hcl for a
+// distribution and PG major between two source trees.
+type versionChange struct {
+	Extension    string
+	Distribution string
+	PgMajor      string
+	FromPackage  string
+	ToPackage    string
+	FromSQL      string
+	ToSQL        string
+}
+
+// String returns the extension, distribution and PG major of the change.
+func (c versionChange) String() string {
+	return fmt.Sprintf("%s (%s, PostgreSQL %s)", c.Extension, c.Distribution, c.PgMajor)
+}
+
+// isDowngrade reports whether the package version was lowered.
+func (c versionChange) isDowngrade() bool {
+	return compareDebianVersions(c.ToPackage, c.FromPackage) < 0
+}
+
+// changesSQL reports whether the SQL version was changed. Versions without a
+// sql attribute are ignored.
+func (c versionChange) changesSQL() bool {
+	return c.FromSQL != "" && c.ToSQL != "" && c.FromSQL != c.ToSQL
+}
+
+// diffVersions returns the changes of the versions declared for the
+// distributions and PG majors present in both version maps, sorted by
+// distribution and PG major.
+func diffVersions(extension string, base versionMap, head versionMap) []versionChange {
+	var changes []versionChange
+	for _, distribution := range slices.Sorted(maps.Keys(head)) {
+		for _, majorVersion := range slices.SortedFunc(maps.Keys(head[distribution]), compareDigits) {
+			from, ok := base[distribution][majorVersion]
+			if !ok {
+				continue
+			}
+			to := head[distribution][majorVersion]
+			if from == to {
+				continue
+			}
+			changes = append(changes, versionChange{
+				Extension:    extension,
+				Distribution: distribution,
+				PgMajor:      majorVersion,
+				FromPackage:  from.Package,
+				ToPackage:    to.Package,
+				FromSQL:      from.SQL,
+				ToSQL:        to.SQL,
+			})
+		}
+	}
+
+	return changes
+}
+
+// packageFilesFunc lists the files shipped by a version of a package of the
+// pgdg repository, for a distribution and PG major.
+type packageFilesFunc func(ctx context.Context, distribution, majorVersion, packageName, version string) ([]string, error)
+
+// pgdgPackageFiles returns the packageFilesFunc listing the files of the
+// packages of the pgdg repository, through the mirror if set.
+func pgdgPackageFiles(mirror string) packageFilesFunc {
+	return func(ctx context.Context, distribution, majorVersion, packageName, version string) ([]string, error) {
+		return getPackageFiles(ctx, pgdgContainer(distribution, majorVersion, mirror), packageName, version)
+	}
+}
+
+// extensionPackageFiles returns the files shipped by every package of an
+// extension at a version, as some extensions split their files across
+// packages, such as the update scripts of postgis in its -scripts package.
+func extensionPackageFiles(
+	ctx context.Context,
+	listFiles packageFilesFunc,
+	metadata *extensionMetadata,
+	distribution string,
+	majorVersion string,
+	version string,
+) ([]string, error) {
+	packages, err := resolvePackages(metadata, majorVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	for _, packageName := range packages {
+		packageFiles, err := listFiles(ctx, distribution, majorVersion, packageName, version)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, packageFiles...)
+	}
+
+	return files, nil
+}
+
+// checkVersionChanges returns the problems of the version changes of an
+// extension: package downgrades, and SQL version changes without a path of
+// update scripts in the new packages.
+func checkVersionChanges(
+	ctx context.Context,
+	metadata *extensionMetadata,
+	changes []versionChange,
+	listFiles packageFilesFunc,
+) ([]string, error) {
+	var problems []string
+	for _, change := range changes {
+		if change.isDowngrade() {
+			problems = append(problems, fmt.Sprintf("%s: package downgraded from %s to %s",
+				change, change.FromPackage, change.ToPackage))
+			continue
+		}
+		if !change.changesSQL() {
+			continue
+		}
+
+		files, err := extensionPackageFiles(ctx, listFiles, metadata, change.Distribution, change.PgMajor, change.ToPackage)
+		if err != nil {
+			return nil, err
+		}
+		if findUpgradePath(parseUpgradeScripts(files, metadata.SQLName), change.FromSQL, change.ToSQL) == nil {
+			packages, err := resolvePackages(metadata, change.PgMajor)
+			if err != nil {
+				return nil, err
+			}
+			problems = append(problems, fmt.Sprintf("%s: no %s--*--*.sql update script path from version %s to %s in %s %s",
+				change, metadata.SQLName, change.FromSQL, change.ToSQL, strings.Join(packages, ", "), change.ToPackage))
+		}
+	}
+
+	return problems, nil
+}
+
+// getPackageFiles returns the files shipped by a version of a package of the
+// pgdg repository, listed from its .deb archive.
+func getPackageFiles(ctx context.Context, container *dagger.Container, packageName string, version string) ([]string, error) {
+	out, err := container.
+		WithEnvVariable("PACKAGE", packageName+"="+version).
+		WithWorkdir("/tmp/packages").
+		WithExec([]string{"sh", "-c", `apt-get download "$PACKAGE" && dpkg-deb -c ./*.deb`}).
+		Stdout(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("while listing the files of %s %s: %w", packageName, version, err)
+	}
+
+	return parseDpkgContents(out), nil
+}
+
+// parseDpkgContents parses the output of dpkg-deb -c, returning the absolute
+// path of every entry.
+func parseDpkgContents(output string) []string {
+	var files []string
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 6 {
+			continue
+		}
+		// Symbolic links are listed as "name -> target"
+		name := fields[5]
+		files = append(files, strings.TrimPrefix(name, "."))
+	}
+
+	return files
+}
+
+// formatVersionChanges reports the version changes and the problems found.
+func formatVersionChanges(changes []versionChange, problems []string) string {
+	if len(changes) == 0 {
+		return "No version changes.\n"
+	}
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "Version changes\n")
+	for _, change := range changes {
+		fmt.Fprintf(&out, "  %s: package %s -> %s", change, change.FromPackage, change.ToPackage)
+		if change.changesSQL() {
+			fmt.Fprintf(&out, ", sql %s -> %s", change.FromSQL, change.ToSQL)
+		}
+		fmt.Fprintf(&out, "\n")
+	}
+	if len(problems) > 0 {
+		fmt.Fprintf(&out, "%d problem(s)\n", len(problems))
+		for _, problem := range problems {
+			fmt.Fprintf(&out, "  - %s\n", problem)
+		}
+	}
+
+	return out.String()
+}
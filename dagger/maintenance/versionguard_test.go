@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestDiffVersions(t *testing.T) {
+	base := versionMap{
+		"bookworm": {
+			"17": {Package: "0.8.0-1.pgdg12+1", SQL: "0.8.0"},
+			"18": {Package: "0.8.1-2.pgdg12+1", SQL: "0.8.1"},
+		},
+		"trixie": {
+			"18": {Package: "0.8.1-2.pgdg13+1", SQL: "0.8.1"},
+		},
+	}
+	head := versionMap{
+		"bookworm": {
+			"17": {Package: "0.8.0-1.pgdg12+1", SQL: "0.8.0"},
+			"18": {Package: "0.8.2-1.pgdg12+1", SQL: "0.8.2"},
+		},
+		"trixie": {
+			"18": {Package: "0.8.1-1.pgdg13+1", SQL: "0.8.1"},
+			"19": {Package: "0.8.2-1.pgdg13+1", SQL: "0.8.2"},
+		},
+	}
+
+	got := diffVersions("pgvector", base, head)
+	want := []versionChange{
+		{
+			Extension:    "pgvector",
+			Distribution: "bookworm",
+			PgMajor:      "18",
+			FromPackage:  "0.8.1-2.pgdg12+1",
+			ToPackage:    "0.8.2-1.pgdg12+1",
+			FromSQL:      "0.8.1",
+			ToSQL:        "0.8.2",
+		},
+		{
+			Extension:    "pgvector",
+			Distribution: "trixie",
+			PgMajor:      "18",
+			FromPackage:  "0.8.1-2.pgdg13+1",
+			ToPackage:    "0.8.1-1.pgdg13+1",
+			FromSQL:      "0.8.1",
+			ToSQL:        "0.8.1",
+		},
+	}
+	if !slices.Equal(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+
+	if got[0].isDowngrade() || !got[0].changesSQL() {
+		t.Errorf("got a downgrade or no SQL change for %v", got[0])
+	}
+	if !got[1].isDowngrade() || got[1].changesSQL() {
+		t.Errorf("got no downgrade or a SQL change for %v", got[1])
+	}
+}
+
+func TestCheckVersionChanges(t *testing.T) {
+	metadata := &extensionMetadata{
+		Name:     "postgis",
+		SQLName:  "postgis",
+		Packages: []string{"postgresql-%version%-postgis-3", "postgresql-%version%-postgis-3-scripts"},
+	}
+	files := map[string][]string{
+		"postgresql-18-postgis-3": {
+			"/usr/lib/postgresql/18/lib/postgis-3.so",
+		},
+		"postgresql-18-postgis-3-scripts": {
+			"/usr/share/postgresql/18/extension/postgis--3.5.2--3.6.0.sql",
+			"/usr/share/postgresql/18/extension/postgis--3.6.0.sql",
+		},
+	}
+	var listed []string
+	listFiles := func(_ context.Context, distribution, majorVersion, packageName, version string) ([]string, error) {
+		listed = append(listed, packageName+"="+version)
+		return files[packageName], nil
+	}
+
+	changes := []versionChange{
+		{
+			Extension: "postgis", Distribution: "trixie", PgMajor: "18",
+			FromPackage: "3.5.2+dfsg-1.pgdg13+1", ToPackage: "3.6.0+dfsg-1.pgdg13+1",
+			FromSQL: "3.5.2", ToSQL: "3.6.0",
+		},
+	}
+	problems, err := checkVersionChanges(context.Background(), metadata, changes, listFiles)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(problems) != 0 {
+		t.Errorf("got problems %v, want none", problems)
+	}
+	want := []string{"postgresql-18-postgis-3=3.6.0+dfsg-1.pgdg13+1", "postgresql-18-postgis-3-scripts=3.6.0+dfsg-1.pgdg13+1"}
+	if !slices.Equal(listed, want) {
+		t.Errorf("got listed packages %v, want %v", listed, want)
+	}
+
+	changes[0].ToSQL = "3.6.1"
+	problems, err = checkVersionChanges(context.Background(), metadata, changes, listFiles)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(problems) != 1 {
+		t.Errorf("got problems %v, want the missing update path", problems)
+	}
+}
+
+func TestVersionChangeChangesSQL(t *testing.T) {
+	change := versionChange{FromPackage: "1.0.0-1", ToPackage: "1.1.0-1", FromSQL: "", ToSQL: "1.1"}
+	if change.changesSQL() {
+		t.Errorf("got a SQL change without a sql attribute in the base")
+	}
+}
+
+func TestParseDpkgContents(t *testing.T) {
+	output := `drwxr-xr-x root/root         0 2025-10-01 10:00 ./
+drwxr-xr-x root/root         0 2025-10-01 10:00 ./usr/share/postgresql/18/extension/
+-rw-r--r-- root/root      1234 2025-10-01 10:00 ./usr/share/postgresql/18/extension/vector--0.8.0--0.8.1.sql
+-rw-r--r-- root/root       180 2025-10-01 10:00 ./usr/share/postgresql/18/extension/vector.control
+lrwxrwxrwx root/root         0 2025-10-01 10:00 ./usr/lib/postgresql/18/lib/vector.so.0 -> vector.so
+`
+	got := parseDpkgContents(output)
+	want := []string{
+		"/",
+		"/usr/share/postgresql/18/extension/",
+		"/usr/share/postgresql/18/extension/vector--0.8.0--0.8.1.sql",
+		"/usr/share/postgresql/18/extension/vector.control",
+		"/usr/lib/postgresql/18/lib/vector.so.0",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	scripts := parseUpgradeScripts(got, "vector")
+	if to := scripts["0.8.0"]; !slices.Equal(to, []string{"0.8.1"}) || len(scripts) != 1 {
+		t.Errorf("got %v, want map[0.8.0:[0.8.1]]", scripts)
+	}
+}
+
+func TestFormatVersionChanges(t *testing.T) {
+	if got, want := formatVersionChanges(nil, nil), "No version changes.\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	change := versionChange{
+		Extension:    "pgvector",
+		Distribution: "trixie",
+		PgMajor:      "18",
+		FromPackage:  "0.8.1-2.pgdg13+1",
+		ToPackage:    "0.8.1-1.pgdg13+1",
+		FromSQL:      "0.8.1",
+		ToSQL:        "0.8.1",
+	}
+	got := formatVersionChanges([]versionChange{change}, []string{"pgvector (trixie, PostgreSQL 18): package downgraded"})
+	want := []string{
+		"Version changes",
+		"  pgvector (trixie, PostgreSQL 18): package 0.8.1-2.pgdg13+1 -> 0.8.1-1.pgdg13+1",
+		"1 problem(s)",
+		"  - pgvector (trixie, PostgreSQL 18): package downgraded",
+		"",
+	}
+	if got != strings.Join(want, "\n") {
+		t.Errorf("got %q, want %q", got, strings.Join(want, "\n"))
+	}
+}
@@ -4,6 +4,7 @@ metadata = {
   name                     = "pg-crash"
   sql_name                 = "pg_crash"
   image_name               = "pg-crash"
+  packages                 = ["postgresql-%version%-pg-crash"]
   licenses                 = ["BSD-3-Clause"]
   shared_preload_libraries = ["pg_crash"]
   postgresql_parameters    = {}
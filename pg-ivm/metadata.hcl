@@ -4,6 +4,7 @@ metadata = {
   name                     = "pg-ivm"
   sql_name                 = "pg_ivm"
   image_name               = "pg-ivm"
+  packages                 = ["postgresql-%version%-pg-ivm"]
   licenses                 = ["PostgreSQL"]
   shared_preload_libraries = ["pg_ivm"]
   postgresql_parameters    = {}
@@ -17,14 +18,6 @@ metadata = {
   create_extension         = true
 
   versions = {
-    trixie = {
-      "18" = {
-        // renovate: suite=trixie-pgdg depName=postgresql-18-pg-ivm
-        package = "1.13-1.pgdg13+1"
-        // renovate: suite=trixie-pgdg depName=postgresql-18-pg-ivm extractVersion=^(?<version>\d+\.\d+)
-        sql     = "1.13"
-      }
-    }
     bookworm = {
       "18" = {
         // renovate: suite=bookworm-pgdg depName=postgresql-18-pg-ivm
@@ -33,5 +26,13 @@ metadata = {
         sql     = "1.13"
       }
     }
+    trixie = {
+      "18" = {
+        // renovate: suite=trixie-pgdg depName=postgresql-18-pg-ivm
+        package = "1.13-1.pgdg13+1"
+        // renovate: suite=trixie-pgdg depName=postgresql-18-pg-ivm extractVersion=^(?<version>\d+\.\d+)
+        sql     = "1.13"
+      }
+    }
   }
 }
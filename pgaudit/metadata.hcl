@@ -1,7 +1,10 @@
+# SPDX-FileCopyrightText: Copyright © contributors to CloudNativePG, established as CloudNativePG a Series of LF Projects, LLC.
+# SPDX-License-Identifier: Apache-2.0
 metadata = {
   name                     = "pgaudit"
   sql_name                 = "pgaudit"
   image_name               = "pgaudit"
+  packages                 = ["postgresql-%version%-pgaudit"]
   licenses                 = ["PostgreSQL"]
   shared_preload_libraries = ["pgaudit"]
   postgresql_parameters    = {}
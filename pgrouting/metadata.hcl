@@ -4,6 +4,7 @@ metadata = {
   name                     = "pgrouting"
   sql_name                 = "pgrouting"
   image_name               = "pgrouting"
+  packages                 = ["postgresql-%version%-pgrouting", "postgresql-%version%-pgrouting-scripts"]
   licenses                 = ["GPL-2.0-or-later"]
   shared_preload_libraries = []
   postgresql_parameters    = {}
@@ -1,7 +1,10 @@
+# SPDX-FileCopyrightText: Copyright © contributors to CloudNativePG, established as CloudNativePG a Series of LF Projects, LLC.
+# SPDX-License-Identifier: Apache-2.0
 metadata = {
   name                     = "pgvector"
   sql_name                 = "vector"
   image_name               = "pgvector"
+  packages                 = ["postgresql-%version%-pgvector"]
   licenses                 = ["PostgreSQL"]
   shared_preload_libraries = []
   postgresql_parameters    = {}
@@ -1,7 +1,10 @@
+# SPDX-FileCopyrightText: Copyright © contributors to CloudNativePG, established as CloudNativePG a Series of LF Projects, LLC.
+# SPDX-License-Identifier: Apache-2.0
 metadata = {
   name                     = "postgis"
   sql_name                 = "postgis"
   image_name               = "postgis-extension"
+  packages                 = ["postgresql-%version%-postgis-3", "postgresql-%version%-postgis-3-scripts"]
   licenses                 = [ "GPL-2.0-or-later", "MIT", "LGPL-2.1-or-later",
                                "GPL-3.0-or-later", "Apache-2.0", "PostgreSQL", "Zlib" ]
   shared_preload_libraries = []
@@ -4,6 +4,7 @@ metadata = {
   name                     = "timescaledb-oss"
   sql_name                 = "timescaledb"
   image_name               = "timescaledb-oss"
+  packages                 = ["postgresql-%version%-timescaledb"]
   licenses                 = ["Apache-2.0", "PostgreSQL"]
   shared_preload_libraries = ["timescaledb"]
   postgresql_parameters    = {}
@@ -17,20 +18,20 @@ metadata = {
   create_extension         = true
 
   versions = {
-    trixie = {
-      "18" = {
-        // renovate: suite=trixie-pgdg depName=postgresql-18-timescaledb
-        package = "2.29.2+dfsg-1.pgdg13+1"
-        // renovate: suite=trixie-pgdg depName=postgresql-18-timescaledb extractVersion=^(?<version>\d+\.\d+\.\d+)
-        sql = "2.29.2"
-      }
-    }
     bookworm = {
       "18" = {
         // renovate: suite=bookworm-pgdg depName=postgresql-18-timescaledb
         package = "2.29.2+dfsg-1.pgdg12+1"
         // renovate: suite=bookworm-pgdg depName=postgresql-18-timescaledb extractVersion=^(?<version>\d+\.\d+\.\d+)
-        sql = "2.29.2"
+        sql     = "2.29.2"
+      }
+    }
+    trixie = {
+      "18" = {
+        // renovate: suite=trixie-pgdg depName=postgresql-18-timescaledb
+        package = "2.29.2+dfsg-1.pgdg13+1"
+        // renovate: suite=trixie-pgdg depName=postgresql-18-timescaledb extractVersion=^(?<version>\d+\.\d+\.\d+)
+        sql     = "2.29.2"
       }
     }
   }